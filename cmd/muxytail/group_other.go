@@ -0,0 +1,9 @@
+//go:build !unix
+
+package muxytail
+
+import "os/exec"
+
+// setGroup does nothing where there are no process groups; stopping
+// cmd kills just the shell.
+func setGroup(cmd *exec.Cmd) {}
@@ -0,0 +1,18 @@
+//go:build unix
+
+package muxytail
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setGroup runs cmd in a process group of its own, and has stopping
+// it signal the whole group, so that the commands of a pipeline
+// such as "journalctl -f | grep sshd" are stopped as well as sh.
+func setGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+	}
+}
@@ -0,0 +1,52 @@
+//go:build unix
+
+package muxytail
+
+import (
+	"context"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/assistcontrol/muxytail/formatter"
+)
+
+func TestWatchCommandStop(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := make(chan *formatter.Entry)
+	done := make(chan struct{})
+
+	// sh starts sleep, then tells its pid
+	go func() {
+		defer close(done)
+		watchCommand(ctx, "sleep 30 & echo $!; wait", pipeline{}, c)
+	}()
+
+	var pid int
+	select {
+	case e := <-c:
+		var err error
+		if pid, err = strconv.Atoi(e.Text); err != nil {
+			t.Fatalf("output = %q, expected a pid", e.Text)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no output from the command")
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("watchCommand() didn't return after cancelling")
+	}
+
+	// The shell's child is stopped too
+	deadline := time.Now().Add(5 * time.Second)
+	for syscall.Kill(pid, 0) == nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("sleep (pid %d) still running", pid)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
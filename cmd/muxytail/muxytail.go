@@ -1,18 +1,36 @@
 package muxytail
 
 import (
+	"bufio"
+	"cmp"
+	"context"
 	"flag"
 	"fmt"
 	"io"
 	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
 	"atomicgo.dev/keyboard"
 	"atomicgo.dev/keyboard/keys"
 	"github.com/assistcontrol/muxytail/config"
+	"github.com/assistcontrol/muxytail/filter"
 	"github.com/assistcontrol/muxytail/formatter"
 	"github.com/assistcontrol/muxytail/formatter/caddy"
+	_ "github.com/assistcontrol/muxytail/formatter/external" // Registers the external formatter
+	"github.com/assistcontrol/muxytail/formatter/journald"
+	"github.com/assistcontrol/muxytail/formatter/prettyjson"
 	"github.com/assistcontrol/muxytail/formatter/regex"
+	_ "github.com/assistcontrol/muxytail/formatter/script" // Registers the script formatter
+	"github.com/assistcontrol/muxytail/formatter/syslog"
+	"github.com/assistcontrol/muxytail/multiline"
 	"github.com/assistcontrol/muxytail/separator"
+	"github.com/assistcontrol/muxytail/stats"
+	"github.com/assistcontrol/muxytail/view"
 
 	"github.com/nxadm/tail"
 )
@@ -20,6 +38,13 @@ import (
 // Default path to the config file. Can be overridden with --config.
 const defaultConfigFile = "/usr/local/etc/muxytail.yaml"
 
+// maxCommandLine is the longest line read from a command's output.
+const maxCommandLine = 1024 * 1024
+
+// stopTimeout is how long to wait for the commands to exit when
+// muxytail quits.
+const stopTimeout = 2 * time.Second
+
 var tailConfig = tail.Config{
 	Location: &tail.SeekInfo{
 		Whence: io.SeekEnd,
@@ -32,62 +57,407 @@ var tailConfig = tail.Config{
 // Run is essentially main(), whereas the real main() is a stub.
 func Run() {
 	configFile := flag.String("config", defaultConfigFile, "config file location")
+	grep := flag.String("grep", "", "only show lines matching this regexp")
+	where := flag.String("where", "", "only show entries whose fields match this expression")
+	panes := flag.Bool("panes", false, "show each file and command in a pane of its own")
+	status := flag.Bool("status", false, "show the line rate and health of each source")
 	flag.Parse()
 
 	conf := config.Load(*configFile)
-	formatters := formatter.List{
-		caddy.New(conf.Caddy),
-		regex.New(conf.Colorize),
+	if err := formatter.SetTime(conf.Time.Format, conf.Time.Zone); err != nil {
+		log.Fatalln("time zone:", err)
+	}
+	formatters := newFormatters(conf)
+
+	// The first formatter with display modes is switched with j
+	var pretty modeCycler
+	for _, f := range formatters {
+		if mc, ok := f.(modeCycler); ok {
+			pretty = mc
+			break
+		}
+	}
+
+	if !formatter.ValidChain(conf.Chain) {
+		log.Fatalf("chain: unknown mode %q", conf.Chain)
 	}
 
+	// Lines of every source pass the global filters and --grep
+	var grepREs []string
+	if *grep != "" {
+		grepREs = []string{*grep}
+	}
+	filters := filter.Set{
+		filter.New(conf.Include, conf.Exclude),
+		filter.New(grepREs, nil),
+	}
+
+	// Entries of every source match the global where and --where
+	wheres := filter.Exprs{
+		compileWhere("where", conf.Where),
+		compileWhere("--where", *where),
+	}
+
+	// Each file sends log entries to logChannel. Every source is set
+	// up, and every file opened, before the display takes over the
+	// terminal, so that config errors are printed normally.
+	logChannel := make(chan *formatter.Entry)
+	sources := &stats.Set{}
+	var sourceIDs, sourceNames []string // in config order, for muting
+	var watchers []func()
+	ctx, stopCommands := context.WithCancel(context.Background())
+	var commands sync.WaitGroup
+	for _, file := range conf.Files {
+		chain := cmp.Or(file.Chain, conf.Chain)
+		if !formatter.ValidChain(chain) {
+			log.Fatalf("%s: chain: unknown mode %q", file.Path, chain)
+		}
+
+		name := filepath.Base(file.Path)
+		sourceIDs = append(sourceIDs, file.Path)
+		sourceNames = append(sourceNames, name)
+		p := pipeline{
+			filters:    append(filter.Set{filter.New(file.Include, file.Exclude)}, filters...),
+			formatters: formatters,
+			chain:      chain,
+			where:      append(filter.Exprs{compileWhere(file.Path+": where", file.Where)}, wheres...),
+			source:     sources.Add(name),
+		}
+		joiner := multiline.New(file.Multiline)
+		t := openFile(file.Path, p.source)
+		watchers = append(watchers, func() { watchFile(file.Path, t, joiner, p, logChannel) })
+	}
+	for _, command := range conf.Commands {
+		sourceIDs = append(sourceIDs, command)
+		sourceNames = append(sourceNames, command)
+		p := pipeline{
+			filters:    filters,
+			formatters: formatters,
+			chain:      conf.Chain,
+			where:      wheres,
+			source:     sources.Add(command),
+		}
+		commands.Add(1)
+		watchers = append(watchers, func() {
+			defer commands.Done()
+			watchCommand(ctx, command, p, logChannel)
+		})
+	}
+	sep := separator.New(conf.Separator)
+
+	// From here on, log messages are shown on the display
+	display := newDisplay(conf, *panes)
+	log.SetOutput(logWriter{display})
+
 	// Watch for Enter
 	separatorChannel := make(chan string)
+	muteChannel := make(chan int)
 	exitChannel := make(chan bool)
-	go watchStdin(separatorChannel, exitChannel, separator.New(conf.Separator))
+	go watchStdin(separatorChannel, muteChannel, exitChannel, sep, pretty, display)
+
+	for _, watch := range watchers {
+		go watch()
+	}
 
-	// Each file sends log lines to logChannel
-	logChannel := make(chan string)
-	for _, path := range conf.Files {
-		go watchFile(path, formatters, logChannel)
+	// The status of the sources is refreshed every second
+	var tick <-chan time.Time
+	if *status || conf.View.SourceStatus {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		tick = ticker.C
 	}
 
+	// Muted sources are still tailed and counted, but not shown
+	muted := make(map[string]bool)
+
 	for {
 		select {
-		case s := <-logChannel:
-			fmt.Println(s)
+		case e := <-logChannel:
+			if !muted[e.Source] {
+				display.Entry(e)
+			}
+		case i := <-muteChannel:
+			if i >= len(sourceIDs) {
+				break
+			}
+			muted[sourceIDs[i]] = !muted[sourceIDs[i]]
+			display.SetMuted(mutedSummary(sourceIDs, sourceNames, muted))
 		case s := <-separatorChannel:
-			fmt.Println(s)
+			display.Line(s)
+		case now := <-tick:
+			sources.Tick(now)
+			display.SetSources(sources.Summary(now))
 		case <-exitChannel:
+			closeDisplay(display)
+			stopCommands()
+			waitTimeout(&commands, stopTimeout)
 			return
 		}
 	}
 }
 
-// watchFile tails a given path and sends all new lines up the provided
-// channel after formatting.
-func watchFile(path string, formatters formatter.List, c chan<- string) {
-	t, err := tail.TailFile(path, tailConfig)
+// screen is where entries and separators end up: the merged
+// stream (view.View) or the split panes (view.Panes).
+type screen interface {
+	Entry(e *formatter.Entry)
+	Line(s string)
+	SetSources(s string)
+	SetMuted(s string)
+	Key(key keys.Key) bool
+	Close()
+}
+
+// newDisplay creates the merged stream display, or the split panes
+// if they are enabled in the config or by --panes.
+func newDisplay(conf *config.MuxytailConf, panes bool) screen {
+	if !panes && !conf.Panes.Enabled {
+		return view.New(conf.View, os.Stdout)
+	}
+
+	switch conf.Panes.Split {
+	case "", view.SplitHorizontal, view.SplitVertical:
+	default:
+		log.Fatalf("panes: split: unknown direction %q", conf.Panes.Split)
+	}
+
+	var sources []string
+	for _, file := range conf.Files {
+		sources = append(sources, file.Path)
+	}
+	sources = append(sources, conf.Commands...)
+
+	return view.NewPanes(conf.Panes, conf.View, sources, os.Stdout)
+}
+
+// closeDisplay restores the terminal, and sends log messages back
+// to stderr.
+func closeDisplay(display screen) {
+	display.Close()
+	log.SetOutput(os.Stderr)
+}
+
+// struct logWriter shows log messages, such as a command exiting,
+// on the display, as writing them to stderr would garble it.
+type logWriter struct {
+	display screen
+}
+
+func (w logWriter) Write(p []byte) (int, error) {
+	w.display.Line(strings.TrimRight(string(p), "\n"))
+	return len(p), nil
+}
+
+// newFormatters creates the configured formatters. Without a
+// formatters list in the config, the built-in formatters run in
+// their default order, each configured by its own section.
+func newFormatters(conf *config.MuxytailConf) formatter.List {
+	if len(conf.Formatters) == 0 {
+		return formatter.List{
+			journald.New(conf.Syslog),
+			caddy.New(conf.Caddy),
+			syslog.New(conf.Syslog),
+			prettyjson.New(conf.JSON),
+			regex.New(conf.Colorize),
+		}
+	}
+
+	formatters := make(formatter.List, 0, len(conf.Formatters))
+	for i, fc := range conf.Formatters {
+		f, err := formatter.New(fc.Type, fc.Decode)
+		if err != nil {
+			log.Fatalf("formatters[%d]: %v", i, err)
+		}
+		formatters = append(formatters, f)
+	}
+
+	return formatters
+}
+
+// compileWhere compiles a field filter expression, exiting with the
+// parse error if it is bad.
+func compileWhere(name, src string) *filter.Expr {
+	x, err := filter.Compile(src)
+	if err != nil {
+		log.Fatalln(name+":", err)
+	}
+
+	return x
+}
+
+// struct pipeline is what each line of a source goes through: line
+// filters, then formatting, then field filters. The source's lines
+// and events are counted for the status line.
+type pipeline struct {
+	filters    filter.Set
+	formatters formatter.List
+	chain      string
+	where      filter.Exprs
+	source     *stats.Source
+}
+
+// send formats a line read from source, and sends the entry up c
+// unless it is filtered out or dropped. Formatting happens in its
+// own goroutine.
+func (p pipeline) send(source, line string, c chan<- *formatter.Entry) {
+	if !p.filters.Match(line) {
+		return
+	}
+
+	go func(e *formatter.Entry) {
+		format(e, p.formatters, p.chain)
+		if !e.Drop && p.where.Match(e) {
+			c <- e
+		}
+	}(formatter.NewEntry(source, line))
+}
+
+// openFile starts tailing a file, exiting if it can't be opened.
+func openFile(path string, source *stats.Source) *tail.Tail {
+	tc := tailConfig
+	tc.Logger = source.Logger() // Reopens are shown in the status line
+	t, err := tail.TailFile(path, tc)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	return t
+}
+
+// watchFile reads the lines of a tailed file and sends them up the
+// provided channel through the pipeline. Lines are first grouped
+// into multi-line entries by joiner.
+func watchFile(path string, t *tail.Tail, joiner *multiline.Joiner, p pipeline, c chan<- *formatter.Entry) {
 	defer func() {
-		if err = t.Stop(); err != nil {
-			log.Fatal(err)
+		if err := t.Stop(); err != nil {
+			log.Println(path+":", err)
 		}
 	}()
 
-	for line := range t.Lines {
-		go func(s string) {
-			c <- format(s, formatters)
-		}(line.Text)
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		for line := range t.Lines {
+			if line.Err != nil {
+				p.source.Error(line.Err)
+				continue
+			}
+			p.source.Line(time.Now()) // Before joining, so that lines are counted and not entries
+			lines <- line.Text
+		}
+		p.source.Stopped(t.Err())
+	}()
+
+	for s := range joiner.Join(lines) {
+		p.send(path, s, c)
 	}
 }
 
-// watchStdin listens for keyboard events. On Enter, a separator is
-// passed up the provided channel. On Esc or ^C, the program exits.
-func watchStdin(sepCh chan<- string, exitCh chan<- bool, sep *separator.Separator) {
+// watchCommand runs a shell command (e.g. journalctl -o json -f)
+// and sends each line of its output up the provided channel through
+// the pipeline, until it exits or ctx is cancelled.
+func watchCommand(ctx context.Context, command string, p pipeline, c chan<- *formatter.Entry) {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	setGroup(cmd)
+	stdout, err := cmd.StdoutPipe()
+	if err == nil {
+		err = cmd.Start()
+	}
+	if err != nil {
+		log.Println(command+":", err)
+		p.source.Stopped(err)
+		return
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(nil, maxCommandLine)
+	for scanner.Scan() {
+		p.source.Line(time.Now())
+		p.send(command, scanner.Text(), c)
+	}
+
+	if err = scanner.Err(); err != nil && ctx.Err() == nil {
+		log.Println(command+":", err)
+		p.source.Error(err)
+	}
+	err = cmd.Wait()
+	if ctx.Err() != nil {
+		return // Stopped on quitting
+	}
+	if err != nil {
+		log.Println(command+":", err)
+	}
+	p.source.Stopped(err)
+}
+
+// waitTimeout waits for wg, but for no longer than timeout.
+func waitTimeout(wg *sync.WaitGroup, timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+	}
+}
+
+// muteKeys are the keys muting the first sources, in config order.
+const muteKeys = "1234567890"
+
+// mutedSummary lists the muted sources with their keys, such as
+// "muted: 2 b.log, 3 journalctl -f", or returns "" if none are. A
+// source listed twice is muted with its first key, so the second
+// listing may be past the keys, and has no key to show.
+func mutedSummary(ids, names []string, muted map[string]bool) string {
+	var list []string
+	for i, id := range ids {
+		switch {
+		case !muted[id]:
+		case i < len(muteKeys):
+			list = append(list, fmt.Sprintf("%s %s", muteKeys[i:i+1], names[i]))
+		default:
+			list = append(list, names[i])
+		}
+	}
+
+	if len(list) == 0 {
+		return ""
+	}
+
+	return "muted: " + strings.Join(list, ", ")
+}
+
+// muteIndex returns the index of the source a key mutes, or -1 if
+// it isn't a mute key.
+func muteIndex(key string) int {
+	if len(key) != 1 {
+		return -1
+	}
+
+	return strings.Index(muteKeys, key)
+}
+
+// modeCycler is implemented by formatters with display modes that
+// can be switched from the keyboard.
+type modeCycler interface {
+	Cycle() string
+}
+
+// watchStdin listens for keyboard events. Keys for the live filter
+// and search (see view.View.Key), or for the panes (see
+// view.Panes.Key), go to the display. On Enter, a
+// separator is passed up the provided channel. On j, the JSON
+// display mode is switched, and the new mode is announced up the
+// same channel. On 1-9 and 0, the index of the source to mute or
+// unmute is passed up muteCh. On q or ^C, the program exits.
+func watchStdin(sepCh chan<- string, muteCh chan<- int, exitCh chan<- bool, sep *separator.Separator, pretty modeCycler, display screen) {
 	onKey := func(key keys.Key) (bool, error) {
+		if key.Code != keys.CtrlC && display.Key(key) {
+			return false, nil // The display's key, or typed into a prompt
+		}
+
 		switch key.Code {
 		case keys.Enter:
 			go func() {
@@ -96,8 +466,23 @@ func watchStdin(sepCh chan<- string, exitCh chan<- bool, sep *separator.Separato
 		case keys.CtrlC:
 			return true, nil // Stop listening
 		case keys.RuneKey:
-			if key.String() == "q" {
+			switch key.String() {
+			case "q":
 				return true, nil
+			case "j":
+				if pretty == nil {
+					break
+				}
+				mode := pretty.Cycle()
+				go func() {
+					sepCh <- sep.Colorizer("json: " + mode)
+				}()
+			default:
+				if i := muteIndex(key.String()); i >= 0 {
+					go func() {
+						muteCh <- i
+					}()
+				}
 			}
 		}
 
@@ -105,22 +490,55 @@ func watchStdin(sepCh chan<- string, exitCh chan<- bool, sep *separator.Separato
 	}
 
 	if err := keyboard.Listen(onKey); err != nil {
+		closeDisplay(display)
 		log.Fatalln("keyboard.Listen:", err)
 	}
 
 	exitCh <- true
 }
 
-// format applies its string argument sequentially to each formatter.
-// Formatting stops after the first formatter that indicates
-// successful formatting.
-func format(in string, formatters formatter.List) string {
+// format applies each formatter to the entry in turn. By default,
+// formatting stops after the first formatter that indicates
+// successful formatting; the chain mode can instead run every
+// formatter, or run highlighters over the structured output (see
+// formatter.ChainFirstMatch and friends).
+func format(e *formatter.Entry, formatters formatter.List, chain string) {
+	switch chain {
+	case formatter.ChainAll:
+		formatAll(e, formatters)
+		return
+
+	case formatter.ChainPipeline, "":
+		var structured, highlighters formatter.List
+		for _, f := range formatters {
+			if _, ok := f.(formatter.Highlighter); ok {
+				highlighters = append(highlighters, f)
+			} else {
+				structured = append(structured, f)
+			}
+		}
+
+		format(e, structured, formatter.ChainFirstMatch)
+		formatAll(e, highlighters)
+		return
+	}
+
 	for _, f := range formatters {
-		if out, ok := f.Format(in); ok {
-			return out
+		if formatter.FormatEntry(f, e) {
+			return
 		}
 	}
 
-	// No formatter was successful
-	return in
+	// No formatter was successful; e.Text is still e.Raw
+}
+
+// formatAll runs every formatter in turn, each over the output of
+// the last one that succeeded, until one drops the entry.
+func formatAll(e *formatter.Entry, formatters formatter.List) {
+	for _, f := range formatters {
+		if e.Drop {
+			return
+		}
+		formatter.FormatEntry(f, e)
+	}
 }
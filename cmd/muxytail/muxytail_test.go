@@ -1,11 +1,32 @@
 package muxytail
 
 import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/assistcontrol/muxytail/config"
+	"github.com/assistcontrol/muxytail/filter"
 	"github.com/assistcontrol/muxytail/formatter"
+	"github.com/assistcontrol/muxytail/multiline"
+	"github.com/assistcontrol/muxytail/stats"
+	"github.com/assistcontrol/muxytail/view"
+	termcolor "github.com/gookit/color"
 )
 
+// formatString runs format over an entry for the line in, and
+// returns the rendered text.
+func formatString(in string, formatters formatter.List, chain string) string {
+	e := formatter.NewEntry("test", in)
+	format(e, formatters, chain)
+
+	return e.Text
+}
+
 type mockFormatter struct {
 	output string
 	ok     bool
@@ -87,10 +108,333 @@ func TestFormat(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := format(tt.input, tt.formatters)
+			result := formatString(tt.input, tt.formatters, formatter.ChainFirstMatch)
 			if result != tt.expected {
 				t.Errorf("expected %q, got %q", tt.expected, result)
 			}
 		})
 	}
 }
+
+// funcFormatter adapts a function to formatter.Formatter.
+type funcFormatter func(string) (string, bool)
+
+func (f funcFormatter) Format(input string) (string, bool) {
+	return f(input)
+}
+
+// funcHighlighter is a funcFormatter that is a formatter.Highlighter.
+type funcHighlighter struct {
+	funcFormatter
+}
+
+func (funcHighlighter) Highlighter() {}
+
+func TestFormatChain(t *testing.T) {
+	// structured only recognizes lines starting with "{"
+	structured := funcFormatter(func(s string) (string, bool) {
+		if !strings.HasPrefix(s, "{") {
+			return s, false
+		}
+		return "S(" + s + ")", true
+	})
+	upper := funcFormatter(func(s string) (string, bool) {
+		return strings.ToUpper(s), true
+	})
+	highlight := funcHighlighter{func(s string) (string, bool) {
+		out := strings.ReplaceAll(s, "x", "[x]")
+		return out, out != s
+	}}
+
+	formatters := formatter.List{structured, highlight, upper}
+
+	tests := []struct {
+		name     string
+		chain    string
+		input    string
+		expected string
+	}{
+		{"First match, structured", formatter.ChainFirstMatch, "{x}", "S({x})"},
+		{"First match, highlight", formatter.ChainFirstMatch, "x", "[x]"},
+		{"Default is pipeline", "", "{x}", "S({[x]})"},
+		{"All", formatter.ChainAll, "{x}", "S({[X]})"},
+		{"All skips failures", formatter.ChainAll, "x", "[X]"},
+		{"Pipeline, structured", formatter.ChainPipeline, "{x}", "S({[x]})"},
+		{"Pipeline, unstructured", formatter.ChainPipeline, "x", "X"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := formatString(tt.input, formatters, tt.chain); result != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestNewFormatters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "muxytail.yaml")
+	data := "formatters:\n  - type: regex\n    '#FF0000': ['ERROR']\n  - type: json\n    mode: off\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	formatters := newFormatters(config.Load(path))
+	if len(formatters) != 2 {
+		t.Fatalf("newFormatters() returned %d formatters, want 2", len(formatters))
+	}
+
+	red := termcolor.HEXStyle("#FF0000").Sprint
+	e := formatter.NewEntry("test", `an ERROR`)
+	format(e, formatters, formatter.ChainAll)
+	if expected := "an " + red("ERROR"); e.Text != expected {
+		t.Errorf("format() = %q, expected %q", e.Text, expected)
+	}
+
+	// The json formatter is off, so JSON passes through untouched
+	e = formatter.NewEntry("test", `{"a": 1}`)
+	format(e, formatters, formatter.ChainFirstMatch)
+	if e.Text != `{"a": 1}` {
+		t.Errorf("format() = %q, expected JSON untouched", e.Text)
+	}
+}
+
+func TestDefaultChainColorize(t *testing.T) {
+	conf := &config.MuxytailConf{Colorize: config.REConfig{"#FF0000": {"ERROR"}}}
+	formatters := newFormatters(conf)
+	red := termcolor.HEXStyle("#FF0000").Sprint
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"Syslog", "Oct 11 22:14:15 myhost app: ERROR boom", "[Oct 11 22:14:15] myhost app              " + red("ERROR") + " boom"},
+		{"Not syslog", "2024-05-01T12:00:00Z INFO server: ERROR started", "2024-05-01T12:00:00Z INFO server: " + red("ERROR") + " started"},
+		{"JSON", `{"a":"ERROR"}`, `{"a": "` + red("ERROR") + `"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := formatString(tt.input, formatters, ""); result != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+// dropper drops entries containing "drop".
+type dropper struct{}
+
+func (dropper) Format(input string) (string, bool) { return input, false }
+
+func (dropper) FormatEntry(e *formatter.Entry) bool {
+	if !strings.Contains(e.Text, "drop") {
+		return false
+	}
+	e.Text, e.Drop = "", true
+
+	return true
+}
+
+func TestFormatDrop(t *testing.T) {
+	upper := funcFormatter(func(s string) (string, bool) {
+		return strings.ToUpper(s), true
+	})
+	formatters := formatter.List{dropper{}, upper}
+
+	for _, chain := range []string{formatter.ChainFirstMatch, formatter.ChainAll, formatter.ChainPipeline} {
+		e := formatter.NewEntry("test", "drop me")
+		format(e, formatters, chain)
+		if !e.Drop || e.Text != "" {
+			t.Errorf("%s: format() = %+v, expected dropped entry", chain, e)
+		}
+
+		e = formatter.NewEntry("test", "keep me")
+		format(e, formatters, chain)
+		if e.Drop || e.Text != "KEEP ME" {
+			t.Errorf("%s: format() = %+v, expected %q", chain, e, "KEEP ME")
+		}
+	}
+}
+
+// statusFormatter parses lines of the form "status=NNN" into fields.
+type statusFormatter struct{}
+
+func (statusFormatter) Format(input string) (string, bool) { return input, false }
+
+func (statusFormatter) FormatEntry(e *formatter.Entry) bool {
+	status, ok := strings.CutPrefix(e.Text, "status=")
+	if !ok {
+		return false
+	}
+	e.Fields = map[string]any{"status": status}
+
+	return true
+}
+
+func TestPipelineSend(t *testing.T) {
+	where, err := filter.Compile("status >= 500")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := pipeline{
+		filters:    filter.Set{filter.New(nil, []string{"healthz"})},
+		formatters: formatter.List{statusFormatter{}},
+		where:      filter.Exprs{where},
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected bool
+	}{
+		{"Passes", "status=502", true},
+		{"Excluded line", "status=502 healthz", false},
+		{"Unmatched fields", "status=200", false},
+		{"No fields", "hello", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := make(chan *formatter.Entry)
+			p.send("test", tt.input, c)
+
+			select {
+			case e := <-c:
+				if !tt.expected {
+					t.Errorf("send() sent %q, expected it filtered out", e.Text)
+				}
+			case <-time.After(50 * time.Millisecond):
+				if tt.expected {
+					t.Error("send() didn't send the entry")
+				}
+			}
+		})
+	}
+}
+
+func TestWatchFileCountsLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	sources := &stats.Set{}
+	p := pipeline{source: sources.Add("app.log")}
+	joiner := multiline.New(config.MultilineConfig{Start: `^\d`})
+	c := make(chan *formatter.Entry)
+
+	start := time.Unix(0, 0)
+	sources.Tick(start)
+	go watchFile(path, openFile(path, p.source), joiner, p, c)
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// Wait for the tail to start before writing
+	time.Sleep(100 * time.Millisecond)
+	if _, err = f.WriteString("1 panic\n  at a()\n  at b()\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case e := <-c:
+		if strings.Count(e.Text, "\n") != 2 {
+			t.Errorf("entry = %q, expected three lines", e.Text)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no entry")
+	}
+
+	// Three lines in one entry are three lines a second
+	sources.Tick(start.Add(time.Second))
+	if got := sources.Summary(start); !strings.HasPrefix(got, "app.log 3.0/s") {
+		t.Errorf("Summary() = %q, expected 3 lines/s", got)
+	}
+}
+
+func TestMuteIndex(t *testing.T) {
+	tests := []struct {
+		name     string
+		key      string
+		expected int
+	}{
+		{"First", "1", 0},
+		{"Ninth", "9", 8},
+		{"Tenth", "0", 9},
+		{"Not a mute key", "x", -1},
+		{"Empty", "", -1},
+		{"Pasted", "12", -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := muteIndex(tt.key); got != tt.expected {
+				t.Errorf("muteIndex(%q) = %d, expected %d", tt.key, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMutedSummary(t *testing.T) {
+	ids := []string{"/var/log/a.log", "/var/log/b.log", "journalctl -f"}
+	names := []string{"a.log", "b.log", "journalctl -f"}
+
+	tests := []struct {
+		name     string
+		muted    map[string]bool
+		expected string
+	}{
+		{"None", map[string]bool{}, ""},
+		{"Unmuted again", map[string]bool{"/var/log/a.log": false}, ""},
+		{"Some", map[string]bool{"/var/log/b.log": true, "journalctl -f": true}, "muted: 2 b.log, 3 journalctl -f"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mutedSummary(ids, names, tt.muted); got != tt.expected {
+				t.Errorf("mutedSummary() = %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMutedSummaryPastKeys(t *testing.T) {
+	// a.log is listed again as the thirteenth source, past the keys
+	var ids, names []string
+	for i := range 11 {
+		ids = append(ids, fmt.Sprintf("/var/log/%d.log", i))
+		names = append(names, fmt.Sprintf("%d.log", i))
+	}
+	ids = append([]string{"/var/log/a.log"}, ids...)
+	names = append([]string{"a.log"}, names...)
+	ids = append(ids, "/var/log/a.log")
+	names = append(names, "a.log")
+
+	got := mutedSummary(ids, names, map[string]bool{"/var/log/a.log": true})
+	if expected := "muted: 1 a.log, a.log"; got != expected {
+		t.Errorf("mutedSummary() = %q, expected %q", got, expected)
+	}
+}
+
+func TestLogWriter(t *testing.T) {
+	var b strings.Builder
+	v := view.New(config.ViewConfig{}, &b)
+
+	log.SetOutput(logWriter{v})
+	log.SetFlags(0)
+	defer func() {
+		log.SetOutput(os.Stderr)
+		log.SetFlags(log.LstdFlags)
+	}()
+
+	log.Println("ls: exit status 1")
+	if got := b.String(); got != "ls: exit status 1\n" {
+		t.Errorf("output = %q", got)
+	}
+}
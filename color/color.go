@@ -2,11 +2,28 @@ package color
 
 import (
 	"fmt"
+	"hash/fnv"
+	"regexp"
 	"strings"
 
 	termcolor "github.com/gookit/color"
 )
 
+// Hash is the color string that selects hash coloring: each value
+// is colored with one of HashPalette, chosen by hashing the value,
+// so that the same value (IP, hostname, user, trace ID) always gets
+// the same color.
+const Hash = "hash"
+
+// HashPalette is the set of colors used by hash coloring. They are
+// picked to be distinguishable from each other on a dark terminal.
+var HashPalette = []string{
+	"#E6194B", "#3CB44B", "#FFE119", "#4363D8",
+	"#F58231", "#46F0F0", "#F032E6", "#BCF60C",
+	"#FABEBE", "#008080", "#E6BEFF", "#9A6324",
+	"#FFFAC8", "#AAFFC3", "#808000", "#FFD8B1",
+}
+
 // Colorizer is the signature for a colorizer function.
 type Colorizer func(...any) string
 
@@ -17,6 +34,10 @@ func GenerateColorizer(s string) Colorizer {
 		return fmt.Sprint
 	}
 
+	if s == Hash {
+		return hashColorizer()
+	}
+
 	// Split into [FG, BG]
 	parts := strings.Split(s, "|")
 
@@ -27,3 +48,37 @@ func GenerateColorizer(s string) Colorizer {
 
 	return tclr.Sprint
 }
+
+// hashColorizer returns a function that colorizes its argument with
+// the HashPalette color selected by the argument's FNV-1a hash.
+func hashColorizer() Colorizer {
+	styles := make([]*termcolor.RGBStyle, len(HashPalette))
+	for i, hex := range HashPalette {
+		styles[i] = termcolor.HEXStyle(hex)
+	}
+
+	return func(a ...any) string {
+		s := fmt.Sprint(a...)
+
+		h := fnv.New32a()
+		h.Write([]byte(s))
+
+		return styles[h.Sum32()%uint32(len(styles))].Sprint(s)
+	}
+}
+
+// escapeRE matches the terminal color escape sequences written by
+// colorizers.
+var escapeRE = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// Strip removes color escape sequences from s, leaving the text as
+// it reads on the terminal.
+func Strip(s string) string {
+	return escapeRE.ReplaceAllString(s, "")
+}
+
+// Escapes returns the positions of the color escape sequences in s,
+// as pairs of indexes like those of regexp's FindAllStringIndex.
+func Escapes(s string) [][]int {
+	return escapeRE.FindAllStringIndex(s, -1)
+}
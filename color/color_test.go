@@ -43,3 +43,55 @@ func TestGenerateColorizer(t *testing.T) {
 		})
 	}
 }
+
+func TestHashColorizer(t *testing.T) {
+	colorizer := GenerateColorizer(Hash)
+
+	values := []string{"192.0.2.1", "192.0.2.2", "example.com", "alice", "4bf92f3577b34da6"}
+	seen := make(map[string]bool)
+
+	for _, v := range values {
+		got := colorizer(v)
+
+		// Deterministic: the same value always gets the same color
+		if again := colorizer(v); again != got {
+			t.Errorf("colorizer(%q) = %q, then %q", v, got, again)
+		}
+
+		// Colored with one of the palette colors
+		found := ""
+		for _, hex := range HashPalette {
+			if got == color.HEXStyle(hex).Sprint(v) {
+				found = hex
+				break
+			}
+		}
+		if found == "" {
+			t.Errorf("colorizer(%q) = %q, not a palette color", v, got)
+		}
+
+		seen[found] = true
+	}
+
+	if len(seen) < 2 {
+		t.Error("every value got the same color")
+	}
+}
+
+func TestStrip(t *testing.T) {
+	red := GenerateColorizer("#FF0000")
+	hash := GenerateColorizer(Hash)
+
+	in := "a " + red("b") + " " + hash("c") + " d"
+	if got := Strip(in); got != "a b c d" {
+		t.Errorf("Strip() = %q, want %q", got, "a b c d")
+	}
+}
+
+func TestEscapes(t *testing.T) {
+	in := "a \x1b[31mb\x1b[0m"
+	got := Escapes(in)
+	if len(got) != 2 || in[got[0][0]:got[0][1]] != "\x1b[31m" || in[got[1][0]:got[1][1]] != "\x1b[0m" {
+		t.Errorf("Escapes() = %v", got)
+	}
+}
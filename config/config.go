@@ -1,29 +1,234 @@
 package config
 
 import (
+	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 // MuxytailConf is the root data structure holding configuration.
 type MuxytailConf struct {
-	Files     []string        `yaml:"files"`
+	Chain    string       `yaml:"chain"`
+	Files    []FileConfig `yaml:"files"`
+	Commands []string     `yaml:"commands"`
+
+	// Include and Exclude are regexps filtering the lines of every
+	// file and command: only lines matching one of Include (if any)
+	// and none of Exclude are shown.
+	Include []string `yaml:"include"`
+	Exclude []string `yaml:"exclude"`
+
+	// Where is a field filter expression (see filter.Expr) that
+	// entries of every file and command must match.
+	Where string `yaml:"where"`
+
+	// Formatters lists the formatters to run, in order. If it is
+	// empty, the built-in formatters run, configured by the
+	// sections below.
+	Formatters []FormatterConfig `yaml:"formatters"`
+
+	Time      TimeConfig      `yaml:"time"`
 	Colorize  REConfig        `yaml:"colorize"`
 	Separator SeparatorConfig `yaml:"separator"`
+	View      ViewConfig      `yaml:"view"`
+	Panes     PanesConfig     `yaml:"panes"`
 	Caddy     CaddyConfig     `yaml:"caddy"`
+	Syslog    SyslogConfig    `yaml:"syslog"`
+	JSON      JSONConfig      `yaml:"json"`
+}
+
+// struct FileConfig is the configuration for a single tailed file.
+// In YAML it is either just the path, or a mapping with a path and
+// per-file settings.
+type FileConfig struct {
+	Path      string          `yaml:"path"`
+	Chain     string          `yaml:"chain"`
+	Multiline MultilineConfig `yaml:"multiline"`
+
+	// Include and Exclude filter the file's lines, as well as the
+	// global filters. Where is likewise a field filter expression
+	// for the file's entries.
+	Include []string `yaml:"include"`
+	Exclude []string `yaml:"exclude"`
+	Where   string   `yaml:"where"`
+}
+
+// UnmarshalYAML accepts either a bare path or a mapping.
+func (f *FileConfig) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		return value.Decode(&f.Path)
+	}
+
+	type plain FileConfig // Avoids recursing into UnmarshalYAML
+	return value.Decode((*plain)(f))
+}
+
+// struct FormatterConfig is a single entry of the formatters list.
+// Type names a registered formatter; the rest of the mapping is that
+// formatter's configuration, and is decoded by it with Decode.
+type FormatterConfig struct {
+	Type string `yaml:"type"`
+
+	settings yaml.Node
+}
+
+// UnmarshalYAML splits the type from the formatter's settings.
+func (f *FormatterConfig) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: formatter must be a mapping", value.Line)
+	}
+
+	f.settings = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Line: value.Line}
+	for i := 0; i+1 < len(value.Content); i += 2 {
+		key, val := value.Content[i], value.Content[i+1]
+		if key.Value == "type" {
+			if err := val.Decode(&f.Type); err != nil {
+				return err
+			}
+			continue
+		}
+		f.settings.Content = append(f.settings.Content, key, val)
+	}
+
+	if f.Type == "" {
+		return fmt.Errorf("line %d: formatter has no type", value.Line)
+	}
+
+	return nil
+}
+
+// Decode decodes the formatter's settings into v, a pointer to the
+// formatter's config struct.
+func (f FormatterConfig) Decode(v any) error {
+	return f.settings.Decode(v)
+}
+
+// struct MultilineConfig describes how lines of a file are grouped
+// into multi-line entries such as stack traces. Start matches the
+// first line of an entry; Continuation matches the lines that
+// follow it. Either may be used alone. A pending entry is sent on
+// after Timeout without new lines, or once it reaches MaxLines.
+type MultilineConfig struct {
+	Start        string        `yaml:"start"`
+	Continuation string        `yaml:"continuation"`
+	Timeout      time.Duration `yaml:"timeout"`
+	MaxLines     int           `yaml:"max_lines"`
 }
 
 // struct ColorConfig is the caddy-specific color struct for the
 // caddy formatter.
 type CaddyConfig struct {
+	Bot         string `yaml:"bot"`
 	Bracket     string `yaml:"bracket"`
+	Duration    string `yaml:"duration"`
+	Geo         string `yaml:"geo"`
 	Host        string `yaml:"host"`
+	Logger      string `yaml:"logger"`
+	Size        string `yaml:"size"`
 	StatusOK    string `yaml:"status_ok"`
 	StatusError string `yaml:"status_error"`
 	StatusOther string `yaml:"status_other"`
+	Status1xx   string `yaml:"status_1xx"`
+	Status3xx   string `yaml:"status_3xx"`
+	Status4xx   string `yaml:"status_4xx"`
+	Status5xx   string `yaml:"status_5xx"`
+	UA          string `yaml:"ua"`
+	Upstream    string `yaml:"upstream"`
 	URL         string `yaml:"url"`
+	User        string `yaml:"user"`
+
+	// UADetail is how much of the user agent to show: short (the
+	// default), full, tags or raw. Bots lists regexps that mark a
+	// user agent as a bot (colored with Bot) whatever the parser
+	// makes of it.
+	UADetail string   `yaml:"ua_detail"`
+	Bots     []string `yaml:"bots"`
+
+	// RawSizes prints response sizes as byte counts rather than
+	// human-readable units.
+	RawSizes bool `yaml:"raw_sizes"`
+
+	// Slow lists request duration thresholds. Durations above a
+	// threshold take its color; the highest exceeded one wins.
+	Slow []SlowConfig `yaml:"slow"`
+
+	// Layout is a text/template for access log lines, executed over
+	// the parsed log entry. Styles maps names to color strings for
+	// use with the template's style function.
+	Layout string            `yaml:"layout"`
+	Styles map[string]string `yaml:"styles"`
+
+	// RelativeTime appends to timestamps the time elapsed since the
+	// previous line of the same file or command.
+	RelativeTime bool `yaml:"relative_time"`
+
+	// GeoIPCountry and GeoIPASN are paths to MaxMind-format (.mmdb)
+	// country and ASN databases used to annotate client IPs. Both
+	// are optional, and may name the same file.
+	GeoIPCountry string `yaml:"geoip_country"`
+	GeoIPASN     string `yaml:"geoip_asn"`
+
+	// StatusCodes maps individual status codes (404, 429) to colors,
+	// overriding the class colors above. Unset class colors fall
+	// back to StatusOther (1xx, 3xx) or StatusError (4xx, 5xx).
+	StatusCodes map[int]string `yaml:"status_codes"`
+
+	// Levels maps a caddy log level (debug, info, warn, error,
+	// panic, fatal) to the color used for non-access log entries.
+	Levels map[string]string `yaml:"levels"`
+}
+
+// struct TimeConfig is how the caddy, syslog and journald
+// formatters display timestamps. Format is a Go time layout, e.g.
+// "2/Jan 15:04:05.000" for millisecond precision. Zone is "local"
+// (the default), "UTC", or an IANA name.
+type TimeConfig struct {
+	Format string `yaml:"format"`
+	Zone   string `yaml:"zone"`
+}
+
+// struct SlowConfig is a single caddy request duration threshold.
+// Over is in seconds.
+type SlowConfig struct {
+	Over  float64 `yaml:"over"`
+	Color string  `yaml:"color"`
+}
+
+// struct JSONConfig is the configuration for pretty-printing JSON
+// lines that no other formatter recognized. Mode is the initial
+// display mode: inline (the default), expand, flatten or off.
+type JSONConfig struct {
+	Key    string `yaml:"key"`
+	String string `yaml:"string"`
+	Number string `yaml:"number"`
+	Bool   string `yaml:"bool"`
+	Null   string `yaml:"null"`
+	Punct  string `yaml:"punct"`
+	Mode   string `yaml:"mode"`
+}
+
+// struct ExternalConfig is the configuration for a formatter that
+// pipes entries through a long-running external process. Protocol
+// is line (the default: one line in, one line out, an empty line
+// declining) or json (see formatter/external). Timeout is how long
+// to wait for each response, 1s by default.
+type ExternalConfig struct {
+	Command  string        `yaml:"command"`
+	Protocol string        `yaml:"protocol"`
+	Timeout  time.Duration `yaml:"timeout"`
+}
+
+// struct ScriptConfig is the configuration for a Starlark script
+// formatter. The script is read from File, or given inline as
+// Script (not both), and must define format(line, fields).
+// MaxSteps limits the work done per line (1000000 by default).
+type ScriptConfig struct {
+	File     string `yaml:"file"`
+	Script   string `yaml:"script"`
+	MaxSteps uint64 `yaml:"max_steps"`
 }
 
 // struct REConfig is a table of color strings that
@@ -31,11 +236,64 @@ type CaddyConfig struct {
 // regexps get colorized according to the string key.
 type REConfig map[string][]string
 
+// struct SyslogConfig is the syslog-specific configuration. Severity
+// maps a severity keyword (emerg, alert, crit, err, warning, notice,
+// info, debug) to the color used for messages of that severity.
+// ProgramWidth is the width the program column is padded to; unset
+// means the default (16), and 0 turns the padding off.
+type SyslogConfig struct {
+	Bracket        string            `yaml:"bracket"`
+	Host           string            `yaml:"host"`
+	Program        string            `yaml:"program"`
+	PID            string            `yaml:"pid"`
+	StructuredData string            `yaml:"structured_data"`
+	Severity       map[string]string `yaml:"severity"`
+	ProgramWidth   *int              `yaml:"program_width"`
+	ConvertTime    bool              `yaml:"convert_time"`
+}
+
 // struct SeparatorConfig is the separator-specific configuration.
 type SeparatorConfig struct {
 	Color string `yaml:"color"`
 }
 
+// struct ViewConfig is the configuration for the interactive
+// display. Highlight colors matches of the search, and Status the
+// status line showing the live filter, search and prompts.
+// PauseBuffer is how many lines are held while output is paused
+// (10000 by default); beyond that the oldest are dropped. History
+// is how many lines are kept for the pager (10000 by default).
+// SourceStatus adds the line rate and health of each source to the
+// status line.
+type ViewConfig struct {
+	Highlight    string `yaml:"highlight"`
+	Status       string `yaml:"status"`
+	PauseBuffer  int    `yaml:"pause_buffer"`
+	History      int    `yaml:"history"`
+	SourceStatus bool   `yaml:"source_status"`
+}
+
+// struct PanesConfig is the configuration for the split-pane
+// display, used instead of the merged stream if Enabled (or with
+// --panes). Split is "horizontal" (panes stacked, the default) or
+// "vertical" (side by side). Groups puts several sources in one
+// pane; every other file and command gets a pane of its own. Title
+// colors the titles of unfocused panes; the focused one takes the
+// view's status color.
+type PanesConfig struct {
+	Enabled bool        `yaml:"enabled"`
+	Split   string      `yaml:"split"`
+	Title   string      `yaml:"title"`
+	Groups  []PaneGroup `yaml:"groups"`
+}
+
+// struct PaneGroup is a pane showing several sources, given as file
+// paths or commands.
+type PaneGroup struct {
+	Title   string   `yaml:"title"`
+	Sources []string `yaml:"sources"`
+}
+
 // Load reads the config file and parses the YAML into a MuxytailConf.
 func Load(path string) *MuxytailConf {
 	// Read in the config file
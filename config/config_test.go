@@ -2,6 +2,7 @@ package config
 
 import (
 	"testing"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -16,35 +17,161 @@ func TestLoad(t *testing.T) {
 		{
 			name: "Valid config",
 			fileData: `
+chain: "pipeline"
 files:
   - "/var/log/syslog"
+  - path: "/var/log/app.log"
+    chain: "all"
+    exclude: ["^DEBUG"]
+    where: "level != debug"
+    multiline:
+      start: '^\d{4}-'
+      timeout: 500ms
+commands:
+  - "journalctl -o json -f"
+include: ["error"]
+exclude: ["/healthz", "kube-probe"]
+where: "status >= 500"
+formatters:
+  - type: "caddy"
+    host: "blue"
+  - type: "regex"
+time:
+  format: "15:04:05.000"
+  zone: "UTC"
 colorize:
   "error": ["ERROR", "FATAL"]
 separator:
   color: "#FF5733"
+view:
+  highlight: "#000000|#FFFF00"
+  pause_buffer: 500
+  history: 2000
+  source_status: true
+panes:
+  enabled: true
+  split: "vertical"
+  groups:
+    - title: "web"
+      sources: ["/var/log/caddy.log", "/var/log/app.log"]
+json:
+  key: "blue"
+  mode: "flatten"
 caddy:
   bracket: "[]"
   host: "localhost"
   status_ok: "green"
   status_error: "red"
   status_other: "yellow"
+  status_5xx: "magenta"
+  status_codes:
+    404: "gray"
   url: "http://example.com"
+  levels:
+    error: "red"
+  slow:
+    - over: 1
+      color: "red"
+  layout: "{{style \"method\" .Req.Method}} {{.URL}}"
+  styles:
+    method: "blue"
+  relative_time: true
+  geoip_country: "/tmp/country.mmdb"
+  geoip_asn: "/tmp/asn.mmdb"
+  bot: "gray"
+  ua_detail: "full"
+  bots:
+    - "^curl/"
+syslog:
+  program: "#00FFFF"
+  program_width: 20
+  convert_time: true
+  severity:
+    err: "#FF0000"
 `,
 			expected: &MuxytailConf{
-				Files: []string{"/var/log/syslog"},
+				Chain: "pipeline",
+				Files: []FileConfig{
+					{Path: "/var/log/syslog"},
+					{
+						Path:    "/var/log/app.log",
+						Chain:   "all",
+						Exclude: []string{"^DEBUG"},
+						Where:   "level != debug",
+						Multiline: MultilineConfig{
+							Start:   `^\d{4}-`,
+							Timeout: 500 * time.Millisecond,
+						},
+					},
+				},
+				Commands: []string{"journalctl -o json -f"},
+				Include:  []string{"error"},
+				Exclude:  []string{"/healthz", "kube-probe"},
+				Where:    "status >= 500",
+				Formatters: []FormatterConfig{
+					{Type: "caddy"},
+					{Type: "regex"},
+				},
+				Time: TimeConfig{
+					Format: "15:04:05.000",
+					Zone:   "UTC",
+				},
 				Colorize: REConfig{
 					"error": {"ERROR", "FATAL"},
 				},
 				Separator: SeparatorConfig{
 					Color: "#FF5733",
 				},
+				View: ViewConfig{
+					Highlight:    "#000000|#FFFF00",
+					PauseBuffer:  500,
+					History:      2000,
+					SourceStatus: true,
+				},
+				Panes: PanesConfig{
+					Enabled: true,
+					Split:   "vertical",
+					Groups: []PaneGroup{
+						{Title: "web", Sources: []string{"/var/log/caddy.log", "/var/log/app.log"}},
+					},
+				},
+				JSON: JSONConfig{
+					Key:  "blue",
+					Mode: "flatten",
+				},
 				Caddy: CaddyConfig{
 					Bracket:     "[]",
 					Host:        "localhost",
 					StatusOK:    "green",
 					StatusError: "red",
 					StatusOther: "yellow",
+					Status5xx:   "magenta",
+					StatusCodes: map[int]string{404: "gray"},
 					URL:         "http://example.com",
+					Levels: map[string]string{
+						"error": "red",
+					},
+					Slow: []SlowConfig{
+						{Over: 1, Color: "red"},
+					},
+					Layout: `{{style "method" .Req.Method}} {{.URL}}`,
+					Styles: map[string]string{
+						"method": "blue",
+					},
+					RelativeTime: true,
+					GeoIPCountry: "/tmp/country.mmdb",
+					GeoIPASN:     "/tmp/asn.mmdb",
+					Bot:          "gray",
+					UADetail:     "full",
+					Bots:         []string{"^curl/"},
+				},
+				Syslog: SyslogConfig{
+					Program:      "#00FFFF",
+					ProgramWidth: intPtr(20),
+					ConvertTime:  true,
+					Severity: map[string]string{
+						"err": "#FF0000",
+					},
 				},
 			},
 			wantErr: false,
@@ -91,3 +218,57 @@ func yamlEqual(a, b interface{}) bool {
 	by, _ := yaml.Marshal(b)
 	return string(ay) == string(by)
 }
+
+func TestFormatterConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileData string
+		expected CaddyConfig
+		wantErr  bool
+	}{
+		{
+			name:     "Settings beside type",
+			fileData: "formatters:\n  - type: caddy\n    host: blue\n    raw_sizes: true\n",
+			expected: CaddyConfig{Host: "blue", RawSizes: true},
+		},
+		{
+			name:     "Type only",
+			fileData: "formatters:\n  - type: caddy\n",
+		},
+		{
+			name:     "Missing type",
+			fileData: "formatters:\n  - host: blue\n",
+			wantErr:  true,
+		},
+		{
+			name:     "Not a mapping",
+			fileData: "formatters:\n  - caddy\n",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := unmarshal([]byte(tt.fileData))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			var conf CaddyConfig
+			if err = got.Formatters[0].Decode(&conf); err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if !yamlEqual(conf, tt.expected) {
+				t.Errorf("Decode() = %+v, expected %+v", conf, tt.expected)
+			}
+		})
+	}
+}
+
+// intPtr returns a pointer to n, for optional settings.
+func intPtr(n int) *int {
+	return &n
+}
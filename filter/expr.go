@@ -0,0 +1,692 @@
+package filter
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/assistcontrol/muxytail/formatter"
+)
+
+// An Expr is a compiled field filter expression, such as
+//
+//	status >= 500 and request.host == "api.example.com"
+//	level in (error, warn) or duration > 0.5
+//
+// Expressions are evaluated against the fields a formatter parsed
+// out of an entry. Fields are named by their key, with dots to
+// reach into nested objects. level and source fall back to the
+// entry's own level and source when the entry has no such field.
+//
+// Comparisons are ==, !=, <, <=, >, >=, =~ and !~ (regexp match),
+// and "in (a, b, ...)". Values are numbers, quoted strings, bare
+// words (taken as strings), true, false and null. A field holding an
+// array matches if any element does. A missing field never matches,
+// whatever the comparison. A field on its own matches if it is set
+// and isn't false, null, 0 or "". Conditions are combined with and,
+// or, not and parentheses; &&, || and ! may be used instead.
+type Expr struct {
+	node node
+	src  string
+}
+
+// Compile parses a filter expression. It returns nil for an empty
+// expression, which matches everything.
+func Compile(src string) (*Expr, error) {
+	if strings.TrimSpace(src) == "" {
+		return nil, nil
+	}
+
+	p := &parser{lex: lexer{src: src}}
+	p.next()
+
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.errorf("unexpected %s", p.tok)
+	}
+
+	return &Expr{node: n, src: src}, nil
+}
+
+// String returns the expression as it was written.
+func (x *Expr) String() string {
+	if x == nil {
+		return ""
+	}
+
+	return x.src
+}
+
+// Match reports whether e satisfies the expression. A nil *Expr
+// matches everything.
+func (x *Expr) Match(e *formatter.Entry) bool {
+	if x == nil {
+		return true
+	}
+
+	return x.node.eval(e)
+}
+
+// Exprs is a group of expressions, such as the config's and
+// --where, that an entry must all match.
+type Exprs []*Expr
+
+// Match reports whether e matches every expression in the group.
+func (exprs Exprs) Match(e *formatter.Entry) bool {
+	for _, x := range exprs {
+		if !x.Match(e) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ParseError is a syntax error in a filter expression. Pos is the
+// byte offset of the error.
+type ParseError struct {
+	Src string
+	Pos int
+	Msg string
+}
+
+func (err *ParseError) Error() string {
+	return fmt.Sprintf("%q: column %d: %s", err.Src, err.Pos+1, err.Msg)
+}
+
+//
+// EVALUATION
+//
+
+// node is a node of a parsed expression.
+type node interface {
+	eval(e *formatter.Entry) bool
+}
+
+type andNode struct{ left, right node }
+type orNode struct{ left, right node }
+type notNode struct{ operand node }
+
+func (n andNode) eval(e *formatter.Entry) bool { return n.left.eval(e) && n.right.eval(e) }
+func (n orNode) eval(e *formatter.Entry) bool  { return n.left.eval(e) || n.right.eval(e) }
+func (n notNode) eval(e *formatter.Entry) bool { return !n.operand.eval(e) }
+
+// truthNode is a field on its own.
+type truthNode struct{ path []string }
+
+func (n truthNode) eval(e *formatter.Entry) bool {
+	v, ok := lookup(e, n.path)
+	if !ok {
+		return false
+	}
+
+	return anyOf(v, func(v any) bool {
+		switch v := v.(type) {
+		case nil:
+			return false
+		case bool:
+			return v
+		case string:
+			return v != ""
+		}
+		f, ok := toNumber(v)
+		return !ok || f != 0
+	})
+}
+
+// compareNode compares a field with one or more values. Values are
+// alternatives (for in); a single value otherwise.
+type compareNode struct {
+	path   []string
+	op     string
+	values []literal
+	re     *regexp.Regexp // for =~ and !~
+}
+
+func (n compareNode) eval(e *formatter.Entry) bool {
+	v, ok := lookup(e, n.path)
+	if !ok {
+		return false
+	}
+
+	switch n.op {
+	case "=~":
+		return anyOf(v, func(v any) bool { return n.re.MatchString(toString(v)) })
+	case "!~":
+		return !anyOf(v, func(v any) bool { return n.re.MatchString(toString(v)) })
+	case "!=":
+		return !anyOf(v, func(v any) bool { return n.values[0].compare(v, "==") })
+	}
+
+	op := n.op
+	if op == "in" {
+		op = "=="
+	}
+
+	return anyOf(v, func(v any) bool {
+		for _, lit := range n.values {
+			if lit.compare(v, op) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// literal is a value written in an expression.
+type literal struct {
+	kind tokenKind // tokNumber, tokString, tokTrue, tokFalse or tokNull
+	num  float64
+	str  string
+}
+
+// compare compares a field value with the literal. Values of the
+// wrong type don't match.
+func (lit literal) compare(v any, op string) bool {
+	switch lit.kind {
+	case tokNumber:
+		f, ok := toNumber(v)
+		if !ok {
+			return false
+		}
+		return ordered(compareNumbers(f, lit.num), op)
+
+	case tokString:
+		if _, ok := v.(map[string]any); ok || v == nil {
+			return false
+		}
+		return ordered(strings.Compare(toString(v), lit.str), op)
+
+	case tokTrue, tokFalse:
+		b, ok := v.(bool)
+		return ok && op == "==" && b == (lit.kind == tokTrue)
+
+	case tokNull:
+		return op == "==" && v == nil
+	}
+
+	return false
+}
+
+// compareNumbers returns -1, 0 or 1 as a is less than, equal to or
+// greater than b.
+func compareNumbers(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+
+	return 0
+}
+
+// ordered applies a comparison operator to the result of a
+// three-way comparison.
+func ordered(c int, op string) bool {
+	switch op {
+	case "==":
+		return c == 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+
+	return false
+}
+
+// fieldAliases are alternative names for top-level fields, for
+// names that are easily mistaken: caddy logs the request as
+// "request", but it is Req in the caddy formatter and in its
+// layouts.
+var fieldAliases = map[string]string{
+	"req": "request",
+}
+
+// lookup finds the field at path in e, reporting whether it is
+// present. A top-level field missing under its own name is looked
+// up under its alias, if it has one.
+func lookup(e *formatter.Entry, path []string) (any, bool) {
+	var v any = e.Fields
+	for i, key := range path {
+		m, ok := v.(map[string]any)
+		if ok {
+			v, ok = m[key]
+			if alias, isAlias := fieldAliases[key]; !ok && isAlias && i == 0 {
+				v, ok = m[alias]
+			}
+		}
+		if !ok {
+			return pseudoField(e, path)
+		}
+	}
+
+	return v, true
+}
+
+// pseudoField returns the entry's own level or source, for entries
+// without fields of those names.
+func pseudoField(e *formatter.Entry, path []string) (any, bool) {
+	if len(path) != 1 {
+		return nil, false
+	}
+
+	switch path[0] {
+	case "level":
+		return e.Level, e.Level != ""
+	case "source":
+		return e.Source, e.Source != ""
+	}
+
+	return nil, false
+}
+
+// anyOf applies match to v, or to each element if v is an array.
+func anyOf(v any, match func(any) bool) bool {
+	list, ok := v.([]any)
+	if !ok {
+		return match(v)
+	}
+
+	for _, el := range list {
+		if match(el) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// toNumber converts a field value to a number. Strings holding
+// numbers count, as some sources (journald) only have strings.
+func toNumber(v any) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	}
+
+	return 0, false
+}
+
+// toString converts a scalar field value to a string.
+func toString(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case nil:
+		return "null"
+	}
+
+	return fmt.Sprint(v)
+}
+
+//
+// PARSING
+//
+
+type parser struct {
+	lex lexer
+	tok token
+}
+
+// next advances to the next token.
+func (p *parser) next() {
+	p.tok = p.lex.next()
+}
+
+// errorf returns a ParseError at the current token. Lexing errors
+// take precedence, as they explain the bad token.
+func (p *parser) errorf(format string, args ...any) error {
+	msg := fmt.Sprintf(format, args...)
+	if p.tok.kind == tokError {
+		msg = p.tok.text
+	}
+
+	return &ParseError{Src: p.lex.src, Pos: p.tok.pos, Msg: msg}
+}
+
+// parseOr parses: and ("or" and)*
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.tok.kind == tokOr {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+
+	return left, nil
+}
+
+// parseAnd parses: not ("and" not)*
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.tok.kind == tokAnd {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+
+	return left, nil
+}
+
+// parseNot parses: "not" not | "(" or ")" | condition
+func (p *parser) parseNot() (node, error) {
+	switch p.tok.kind {
+	case tokNot:
+		p.next()
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand}, nil
+
+	case tokLParen:
+		p.next()
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokRParen {
+			return nil, p.errorf("expected ), found %s", p.tok)
+		}
+		p.next()
+		return n, nil
+	}
+
+	return p.parseCondition()
+}
+
+// parseCondition parses: field [op value | "in" "(" values ")"]
+func (p *parser) parseCondition() (node, error) {
+	if p.tok.kind != tokIdent {
+		return nil, p.errorf("expected field name, found %s", p.tok)
+	}
+	path := strings.Split(p.tok.text, ".")
+	for _, key := range path {
+		if key == "" {
+			return nil, p.errorf("bad field name %q", p.tok.text)
+		}
+	}
+	p.next()
+
+	switch p.tok.kind {
+	case tokOp:
+		op := p.tok.text
+		p.next()
+
+		lit, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+
+		n := compareNode{path: path, op: op, values: []literal{lit}}
+
+		switch op {
+		case "=~", "!~":
+			if lit.kind != tokString {
+				return nil, p.errorf("%s needs a regexp string", op)
+			}
+			if n.re, err = regexp.Compile(lit.str); err != nil {
+				return nil, p.errorf("%v", err)
+			}
+		case "<", "<=", ">", ">=":
+			if lit.kind != tokNumber && lit.kind != tokString {
+				return nil, p.errorf("%s needs a number or string", op)
+			}
+		}
+
+		return n, nil
+
+	case tokIn:
+		p.next()
+		if p.tok.kind != tokLParen {
+			return nil, p.errorf("expected ( after in, found %s", p.tok)
+		}
+		p.next()
+
+		n := compareNode{path: path, op: "in"}
+		for {
+			lit, err := p.parseLiteral()
+			if err != nil {
+				return nil, err
+			}
+			n.values = append(n.values, lit)
+
+			if p.tok.kind == tokRParen {
+				p.next()
+				return n, nil
+			}
+			if p.tok.kind != tokComma {
+				return nil, p.errorf("expected , or ), found %s", p.tok)
+			}
+			p.next()
+		}
+	}
+
+	return truthNode{path: path}, nil
+}
+
+// parseLiteral parses a value. Bare words are strings.
+func (p *parser) parseLiteral() (literal, error) {
+	tok := p.tok
+
+	switch tok.kind {
+	case tokNumber:
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return literal{}, p.errorf("bad number %q", tok.text)
+		}
+		p.next()
+		return literal{kind: tokNumber, num: f}, nil
+
+	case tokString, tokIdent:
+		p.next()
+		return literal{kind: tokString, str: tok.text}, nil
+
+	case tokTrue, tokFalse, tokNull:
+		p.next()
+		return literal{kind: tok.kind}, nil
+	}
+
+	return literal{}, p.errorf("expected value, found %s", tok)
+}
+
+//
+// LEXING
+//
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokError
+	tokIdent
+	tokNumber
+	tokString
+	tokOp
+	tokLParen
+	tokRParen
+	tokComma
+	tokAnd
+	tokOr
+	tokNot
+	tokIn
+	tokTrue
+	tokFalse
+	tokNull
+)
+
+// keywords maps keywords and their symbol forms to token kinds.
+var keywords = map[string]tokenKind{
+	"and":   tokAnd,
+	"&&":    tokAnd,
+	"or":    tokOr,
+	"||":    tokOr,
+	"not":   tokNot,
+	"!":     tokNot,
+	"in":    tokIn,
+	"true":  tokTrue,
+	"false": tokFalse,
+	"null":  tokNull,
+}
+
+// operators are the comparison operators, longest first.
+var operators = []string{"==", "!=", "<=", ">=", "=~", "!~", "<", ">", "&&", "||", "!"}
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of expression"
+	case tokString:
+		return strconv.Quote(t.text)
+	}
+
+	return "'" + t.text + "'"
+}
+
+type lexer struct {
+	src string
+	pos int
+}
+
+// next returns the next token. Lexing errors are returned as
+// tokError tokens, which the parser then reports.
+func (l *lexer) next() token {
+	for l.pos < len(l.src) && strings.IndexByte(" \t\n\r", l.src[l.pos]) >= 0 {
+		l.pos++
+	}
+	if l.pos >= len(l.src) {
+		return token{kind: tokEOF, pos: l.pos}
+	}
+
+	start := l.pos
+	c := l.src[l.pos]
+
+	switch {
+	case c == '(':
+		l.pos++
+		return token{kind: tokLParen, text: "(", pos: start}
+	case c == ')':
+		l.pos++
+		return token{kind: tokRParen, text: ")", pos: start}
+	case c == ',':
+		l.pos++
+		return token{kind: tokComma, text: ",", pos: start}
+
+	case c == '"' || c == '\'':
+		return l.lexString(c)
+
+	case isDigit(c) || (c == '-' || c == '.') && l.pos+1 < len(l.src) && isDigit(l.src[l.pos+1]):
+		l.pos++
+		for l.pos < len(l.src) && (isDigit(l.src[l.pos]) || strings.IndexByte(".eE+-", l.src[l.pos]) >= 0) {
+			l.pos++
+		}
+		return token{kind: tokNumber, text: l.src[start:l.pos], pos: start}
+
+	case isIdentStart(c):
+		for l.pos < len(l.src) && isIdent(l.src[l.pos]) {
+			l.pos++
+		}
+		text := l.src[start:l.pos]
+		if kind, ok := keywords[text]; ok {
+			return token{kind: kind, text: text, pos: start}
+		}
+		return token{kind: tokIdent, text: text, pos: start}
+	}
+
+	for _, op := range operators {
+		if strings.HasPrefix(l.src[l.pos:], op) {
+			l.pos += len(op)
+			if kind, ok := keywords[op]; ok {
+				return token{kind: kind, text: op, pos: start}
+			}
+			return token{kind: tokOp, text: op, pos: start}
+		}
+	}
+
+	l.pos++
+	return token{kind: tokError, text: fmt.Sprintf("unexpected character %q", c), pos: start}
+}
+
+// lexString lexes a string quoted with q. Double-quoted strings
+// take Go escapes; single-quoted strings are taken as written,
+// which suits regexps.
+func (l *lexer) lexString(q byte) token {
+	start := l.pos
+	l.pos++
+
+	for l.pos < len(l.src) && l.src[l.pos] != q {
+		if l.src[l.pos] == '\\' && q == '"' {
+			l.pos++
+		}
+		l.pos++
+	}
+	if l.pos >= len(l.src) {
+		return token{kind: tokError, text: "unterminated string", pos: start}
+	}
+	l.pos++
+
+	raw := l.src[start:l.pos]
+	if q == '\'' {
+		return token{kind: tokString, text: raw[1 : len(raw)-1], pos: start}
+	}
+
+	s, err := strconv.Unquote(raw)
+	if err != nil {
+		return token{kind: tokError, text: "bad string " + raw, pos: start}
+	}
+
+	return token{kind: tokString, text: s, pos: start}
+}
+
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
+
+func isIdentStart(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_'
+}
+
+func isIdent(c byte) bool {
+	return isIdentStart(c) || isDigit(c) || c == '.' || c == '-' || c == '@'
+}
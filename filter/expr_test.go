@@ -0,0 +1,154 @@
+package filter
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/assistcontrol/muxytail/formatter"
+)
+
+func TestExprMatch(t *testing.T) {
+	// Fields as the caddy formatter fills them in
+	caddy := &formatter.Entry{
+		Source: "/var/log/caddy/access.log",
+		Level:  "error",
+		Fields: map[string]any{
+			"level":    "error",
+			"status":   502.0,
+			"duration": 0.75,
+			"request": map[string]any{
+				"host":    "api.example.com",
+				"headers": map[string]any{"User-Agent": []any{"kube-probe/1.29"}},
+			},
+			"user_id": "",
+			"tls":     nil,
+		},
+	}
+
+	// Fields as the journald formatter fills them in: all strings
+	journal := &formatter.Entry{
+		Level:  "warn",
+		Fields: map[string]any{"_PID": "1234", "_SYSTEMD_UNIT": "sshd.service"},
+	}
+
+	tests := []struct {
+		expr     string
+		entry    *formatter.Entry
+		expected bool
+	}{
+		{`status >= 500`, caddy, true},
+		{`status < 500`, caddy, false},
+		{`status == 502 and duration > 0.5`, caddy, true},
+		{`request.host == "api.example.com"`, caddy, true},
+		{`request.host != "api.example.com"`, caddy, false},
+		{`request.host =~ '^api\.'`, caddy, true},
+		{`request.host !~ 'example'`, caddy, false},
+		{`request.headers.User-Agent =~ "kube-probe"`, caddy, true},
+		{`level in (error, warn)`, caddy, true},
+		{`level in (info, debug)`, caddy, false},
+		{`not level == info`, caddy, true},
+		{`status >= 500 && !(duration > 1)`, caddy, true},
+		{`status == 200 || request.host == api.example.com`, caddy, true},
+		{`(status == 200 or duration > 0.5) and level == error`, caddy, true},
+		{`status == "502"`, caddy, true},
+		{`tls == null`, caddy, true},
+		{`tls`, caddy, false},
+		{`user_id`, caddy, false},
+		{`duration`, caddy, true},
+		{`source =~ 'caddy'`, caddy, true},
+		{`missing == 1`, caddy, false},
+		{`missing != 1`, caddy, false},
+		{`request.host.name == x`, caddy, false},
+		{`_PID > 1000`, journal, true},
+		{`_SYSTEMD_UNIT == sshd.service`, journal, true},
+		{`level == warn`, journal, true},
+		{`level == warn`, formatter.NewEntry("plain", "no fields"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			x, err := Compile(tt.expr)
+			if err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+			if got := x.Match(tt.entry); got != tt.expected {
+				t.Errorf("Match() = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestRequestExamples checks the expressions from the feature
+// request, verbatim, against a parsed caddy access log.
+func TestRequestExamples(t *testing.T) {
+	e := formatter.NewEntry("access.log", "")
+	e.Level = "error"
+	e.Fields = map[string]any{
+		"level":    "error",
+		"status":   503.0,
+		"duration": 0.75,
+		"request":  map[string]any{"host": "api.example.com"},
+	}
+
+	for _, expr := range []string{
+		`status >= 500`,
+		`req.host == "api.example.com"`,
+		`duration > 0.5`,
+		`level in (error, warn)`,
+	} {
+		t.Run(expr, func(t *testing.T) {
+			x, err := Compile(expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !x.Match(e) {
+				t.Error("Match() = false, expected true")
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		pos  int
+		msg  string
+	}{
+		{`status >=`, 9, "expected value, found end of expression"},
+		{`>= 500`, 0, "expected field name, found '>='"},
+		{`status >= 500 and`, 17, "expected field name, found end of expression"},
+		{`(status >= 500`, 14, "expected ), found end of expression"},
+		{`level in (error warn)`, 16, "expected , or ), found 'warn'"},
+		{`level in error`, 9, "expected ( after in, found 'error'"},
+		{`host == "api`, 8, "unterminated string"},
+		{`status % 2`, 7, `unexpected character '%'`},
+		{`host =~ '('`, 11, "error parsing regexp: missing closing ): `(`"},
+		{`status > true`, 13, "> needs a number or string"},
+		{`status 500`, 7, "unexpected '500'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := Compile(tt.expr)
+
+			var perr *ParseError
+			if !errors.As(err, &perr) {
+				t.Fatalf("Compile() error = %v, expected a ParseError", err)
+			}
+			if perr.Pos != tt.pos || perr.Msg != tt.msg {
+				t.Errorf("Compile() error at %d: %s, expected at %d: %s", perr.Pos, perr.Msg, tt.pos, tt.msg)
+			}
+		})
+	}
+}
+
+func TestCompileEmpty(t *testing.T) {
+	x, err := Compile("  ")
+	if x != nil || err != nil {
+		t.Errorf("Compile() = %v, %v, expected nil, nil", x, err)
+	}
+
+	if !(Exprs{x}).Match(formatter.NewEntry("test", "x")) {
+		t.Error("empty expression doesn't match")
+	}
+}
@@ -0,0 +1,81 @@
+package filter
+
+import (
+	"log"
+	"regexp"
+)
+
+// struct Filter decides which lines are shown. A line is shown if
+// it matches any of Include (or Include is empty), and none of
+// Exclude.
+type Filter struct {
+	Include []*regexp.Regexp
+	Exclude []*regexp.Regexp
+}
+
+// New returns a Filter for the provided regexps, or nil if there
+// are none.
+func New(include, exclude []string) *Filter {
+	if len(include) == 0 && len(exclude) == 0 {
+		return nil
+	}
+
+	return &Filter{
+		Include: compile("include", include),
+		Exclude: compile("exclude", exclude),
+	}
+}
+
+// compile compiles a list of regexps, exiting on a bad one.
+func compile(name string, REs []string) []*regexp.Regexp {
+	compiled := make([]*regexp.Regexp, 0, len(REs))
+	for _, re := range REs {
+		c, err := regexp.Compile(re)
+		if err != nil {
+			log.Fatalln(name+":", err)
+		}
+		compiled = append(compiled, c)
+	}
+
+	return compiled
+}
+
+// Match reports whether s passes the filter. A nil *Filter passes
+// everything.
+func (f *Filter) Match(s string) bool {
+	if f == nil {
+		return true
+	}
+
+	for _, re := range f.Exclude {
+		if re.MatchString(s) {
+			return false
+		}
+	}
+
+	if len(f.Include) == 0 {
+		return true
+	}
+	for _, re := range f.Include {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Set is a group of filters, such as the global filter and a file's
+// own, that a line must all pass.
+type Set []*Filter
+
+// Match reports whether s passes every filter in the set.
+func (set Set) Match(s string) bool {
+	for _, f := range set {
+		if !f.Match(s) {
+			return false
+		}
+	}
+
+	return true
+}
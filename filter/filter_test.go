@@ -0,0 +1,56 @@
+package filter
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   *Filter
+		input    string
+		expected bool
+	}{
+		{"Nil filter", nil, "anything", true},
+		{"Exclude", New(nil, []string{`/healthz`, `kube-probe`}), "GET /healthz 200", false},
+		{"Not excluded", New(nil, []string{`/healthz`}), "GET /index.html 200", true},
+		{"Include", New([]string{`ERROR`, `WARN`}, nil), "WARN disk low", true},
+		{"Not included", New([]string{`ERROR`}, nil), "INFO ok", false},
+		{"Exclude beats include", New([]string{`ERROR`}, []string{`expected`}), "ERROR expected failure", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(tt.input); got != tt.expected {
+				t.Errorf("Match(%q) = %v, expected %v", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNewEmpty(t *testing.T) {
+	if f := New(nil, []string{}); f != nil {
+		t.Errorf("New() = %+v, expected nil", f)
+	}
+}
+
+func TestSetMatch(t *testing.T) {
+	set := Set{
+		New([]string{`app`}, nil), // e.g. --grep
+		nil,
+		New(nil, []string{`debug`}),
+	}
+
+	tests := map[string]bool{
+		"app started":   true,
+		"app debug: x":  false,
+		"other started": false,
+	}
+	for input, expected := range tests {
+		if got := set.Match(input); got != expected {
+			t.Errorf("Match(%q) = %v, expected %v", input, got, expected)
+		}
+	}
+
+	if !(Set{}).Match("anything") {
+		t.Error("empty Set doesn't match")
+	}
+}
@@ -2,26 +2,68 @@
 package caddy
 
 import (
+	"cmp"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"regexp"
+	"slices"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
 
 	"github.com/assistcontrol/muxytail/color"
 	"github.com/assistcontrol/muxytail/config"
+	"github.com/assistcontrol/muxytail/formatter"
 )
 
 // whiteSpaceRE is used to collapse consecutive whitespace into
 // a single space
 var whiteSpaceRE = regexp.MustCompile(`\s+`)
 
+// levelWidth is the width the level column of non-access logs is
+// padded to, which fits every level caddy emits.
+const levelWidth = 5
+
 // struct colorizer holds the colorizer functions generated from
 // the passed caddy config
 type colorizer struct {
-	Bracket                            color.Colorizer
-	Host                               color.Colorizer
-	StatusOK, StatusError, StatusOther color.Colorizer
-	URL                                color.Colorizer
+	Bot                             color.Colorizer
+	Bracket                         color.Colorizer
+	Duration                        color.Colorizer
+	Geo                             color.Colorizer
+	Host                            color.Colorizer
+	Logger                          color.Colorizer
+	Size                            color.Colorizer
+	Status1xx, Status2xx, Status3xx color.Colorizer
+	Status4xx, Status5xx            color.Colorizer
+	StatusCodes                     map[caddyStatus]color.Colorizer
+	UA                              color.Colorizer
+	Upstream                        color.Colorizer
+	URL                             color.Colorizer
+	User                            color.Colorizer
+	Levels                          map[string]color.Colorizer
+	Slow                            []slowColor
+
+	clock    *clock
+	geoIP    *geoIP
+	bots     []*regexp.Regexp
+	layout   *template.Template // never executed, so that it can be cloned
+	layoutMu sync.Mutex
+	layouts  map[string]*template.Template // by source; see layoutFor
+	rawSizes bool
+	uaDetail string
+}
+
+// struct slowColor is a duration threshold above which request
+// durations are colorized by Colorizer.
+type slowColor struct {
+	Over      time.Duration
+	Colorizer color.Colorizer
 }
 
 // Format parses a JSON-formatted caddy log entry, formats it
@@ -30,74 +72,312 @@ type colorizer struct {
 // If bool is false, the input was not a well-formed caddy JSON log
 // entry.
 func (clr *colorizer) Format(in string) (string, bool) {
-	cLog, err := parse(in)
+	e := &formatter.Entry{Raw: in, Text: in}
+	ok := clr.FormatEntry(e)
+
+	return e.Text, ok
+}
+
+// FormatEntry is Format for a formatter.Entry. Besides rendering
+// the text, it fills in the entry's time, level and fields from the
+// parsed JSON.
+func (clr *colorizer) FormatEntry(e *formatter.Entry) bool {
+	cLog, fields, err := parse(e.Text)
 	if err != nil {
-		return in, false
+		return false
+	}
+	cLog.source = e.Source
+
+	if cLog.IsAccess() {
+		out, ok := clr.formatLog(cLog)
+		if !ok {
+			return false // Leave the line to the next formatter
+		}
+		e.Text = whiteSpaceRE.ReplaceAllString(out, " ") // collapse whitespace
+	} else {
+		e.Text = clr.formatOther(cLog)
+	}
+
+	e.Time = cLog.TS.Time()
+	e.Level = cLog.Level
+	e.Fields = fields
+
+	return true
+}
+
+// formatLog takes a parsed caddyLog struct, formats it according
+// to the configured layout, and colorizes it. It reports false if
+// the layout can't be rendered for this entry.
+func (clr *colorizer) formatLog(cLog *caddyLog) (string, bool) {
+	var b strings.Builder
+	if err := clr.layoutFor(cLog.source).Execute(&b, cLog); err != nil {
+		return "", false
+	}
+
+	return b.String(), true
+}
+
+// layoutFor returns the layout for lines from source, whose time
+// function measures relative timestamps within that source. It is
+// cloned from the parsed layout on first use.
+func (clr *colorizer) layoutFor(source string) *template.Template {
+	clr.layoutMu.Lock()
+	defer clr.layoutMu.Unlock()
+
+	if tmpl, ok := clr.layouts[source]; ok {
+		return tmpl
+	}
+
+	tmpl := template.Must(clr.layout.Clone()).Funcs(template.FuncMap{
+		"time": func(cTS caddyTimeStamp) string {
+			return clr.clock.Format(source, cTS)
+		},
+	})
+	clr.layouts[source] = tmpl
+
+	return tmpl
+}
+
+// colorizeDuration colorizes the request duration using the color
+// of the highest slow threshold it exceeds.
+func (clr *colorizer) colorizeDuration(d caddyDuration) string {
+	c := clr.Duration
+	for _, slow := range clr.Slow {
+		if d.Duration() > slow.Over {
+			c = slow.Colorizer
+		}
+	}
+
+	return c(d)
+}
+
+// colorizeGeo colorizes the GeoIP annotation of a client IP. It
+// returns "" if there is nothing to annotate.
+func (clr *colorizer) colorizeGeo(remote caddyRemoteIP) string {
+	s := clr.geoIP.Annotate(remote)
+	if s == "" {
+		return ""
+	}
+
+	return clr.Geo(s)
+}
+
+// colorizeRead renders the size of the request body, as "in:1.2KiB",
+// or "" if there was none.
+func (clr *colorizer) colorizeRead(s caddySize) string {
+	if s == 0 {
+		return ""
+	}
+
+	return clr.Size("in:" + clr.size(s))
+}
+
+// colorizeUpstream renders the backend of a proxied request, as
+// "→10.0.0.5:8080", or "" if it isn't logged.
+func (clr *colorizer) colorizeUpstream(upstream string) string {
+	if upstream == "" {
+		return ""
+	}
+
+	return clr.Upstream("→" + upstream)
+}
+
+// colorizeUA renders the user agent at the configured detail level,
+// colorizing bots differently from everything else.
+func (clr *colorizer) colorizeUA(cUA caddyUserAgent) string {
+	s, bot := cUA.Render(clr.uaDetail, clr.bots)
+	if bot {
+		return clr.Bot(s)
 	}
 
-	out := clr.formatLog(cLog)
-	out = whiteSpaceRE.ReplaceAllString(out, " ") // collapse whitespace
+	return clr.UA(s)
+}
+
+// size renders a response size either human-readable or as a
+// plain byte count, as configured.
+func (clr *colorizer) size(s caddySize) string {
+	if clr.rawSizes {
+		return s.Bytes()
+	}
 
-	return out, true
+	return s.String()
 }
 
-// formatLog takes a parsed caddyLog struct, formats it, and
-// colorizes it.
-func (clr *colorizer) formatLog(cLog *caddyLog) string {
-	bracketL := clr.Bracket("[")
-	bracketR := clr.Bracket("]")
+// formatOther takes a parsed caddyLog struct from one of caddy's
+// own loggers and formats it as "[ts] LEVEL logger: msg key=val...".
+func (clr *colorizer) formatOther(cLog *caddyLog) string {
+	level := strings.ToUpper(cLog.Level)
+	level += strings.Repeat(" ", max(levelWidth-len(level), 0))
 
-	s := fmt.Sprintf("%s %s%s%s %s (%s) %s %s %s %s%s%s",
-		clr.Host(cLog.Req.Remote),
-		bracketL, cLog.TS, bracketR,
-		clr.URL(cLog.URL()),
-		clr.colorizeStatus(cLog.Status),
-		cLog.Req.Method,
-		cLog.Req.Proto,
-		cLog.Req.Headers.Referer,
-		bracketL, cLog.Req.Headers.UA, bracketR,
+	s := fmt.Sprintf("%s%s%s %s %s: %s",
+		clr.Bracket("["), clr.clock.Format(cLog.source, cLog.TS), clr.Bracket("]"),
+		clr.colorizeLevel(cLog.Level, level),
+		clr.Logger(cLog.Logger),
+		clr.colorizeLevel(cLog.Level, cLog.Msg),
 	)
 
+	if len(cLog.Fields) > 0 {
+		s += " " + cLog.Fields.String()
+	}
+
+	return s
+}
+
+// colorizeLevel colorizes s with the color configured for the
+// supplied log level.
+func (clr *colorizer) colorizeLevel(level, s string) string {
+	if c, ok := clr.Levels[level]; ok {
+		return c(s)
+	}
+
 	return s
 }
 
 // colorizeStatus colorizes the supplied HTTP status code
-// based on its numeric range.
+// based on its numeric range, unless the code has its own color.
 func (clr *colorizer) colorizeStatus(statusCode caddyStatus) string {
 	status := strconv.Itoa(int(statusCode))
 
-	if statusCode >= 200 && statusCode < 300 {
-		return clr.StatusOK(status)
-	} else if statusCode >= 400 {
-		return clr.StatusError(status)
-	} else {
-		return clr.StatusOther(status)
+	if c, ok := clr.StatusCodes[statusCode]; ok {
+		return c(status)
+	}
+
+	switch {
+	case statusCode < 200:
+		return clr.Status1xx(status)
+	case statusCode < 300:
+		return clr.Status2xx(status)
+	case statusCode < 400:
+		return clr.Status3xx(status)
+	case statusCode < 500:
+		return clr.Status4xx(status)
+	default:
+		return clr.Status5xx(status)
 	}
 }
 
+// init makes the formatter available as type "caddy".
+func init() {
+	formatter.Register("caddy", formatter.Configured(New))
+}
+
 // New takes a config.CaddyConfig struct specifying color strings,
 // and returns a *colorizer struct of colorization functions that
 // is capable of parsing and formatting caddy JSON log entries.
 func New(conf config.CaddyConfig) *colorizer {
 	c := &colorizer{
+		Bot:         color.GenerateColorizer(conf.Bot),
 		Bracket:     color.GenerateColorizer(conf.Bracket),
+		Duration:    color.GenerateColorizer(conf.Duration),
+		Geo:         color.GenerateColorizer(conf.Geo),
 		Host:        color.GenerateColorizer(conf.Host),
-		StatusOK:    color.GenerateColorizer(conf.StatusOK),
-		StatusError: color.GenerateColorizer(conf.StatusError),
-		StatusOther: color.GenerateColorizer(conf.StatusOther),
+		Logger:      color.GenerateColorizer(conf.Logger),
+		Size:        color.GenerateColorizer(conf.Size),
+		Status1xx:   color.GenerateColorizer(cmp.Or(conf.Status1xx, conf.StatusOther)),
+		Status2xx:   color.GenerateColorizer(conf.StatusOK),
+		Status3xx:   color.GenerateColorizer(cmp.Or(conf.Status3xx, conf.StatusOther)),
+		Status4xx:   color.GenerateColorizer(cmp.Or(conf.Status4xx, conf.StatusError)),
+		Status5xx:   color.GenerateColorizer(cmp.Or(conf.Status5xx, conf.StatusError)),
+		StatusCodes: make(map[caddyStatus]color.Colorizer, len(conf.StatusCodes)),
+		UA:          color.GenerateColorizer(conf.UA),
+		Upstream:    color.GenerateColorizer(conf.Upstream),
 		URL:         color.GenerateColorizer(conf.URL),
+		User:        color.GenerateColorizer(conf.User),
+		Levels:      make(map[string]color.Colorizer, len(conf.Levels)),
+		layouts:     make(map[string]*template.Template),
+		rawSizes:    conf.RawSizes,
+		uaDetail:    conf.UADetail,
+	}
+
+	switch c.uaDetail {
+	case "":
+		c.uaDetail = uaShort
+	case uaShort, uaFull, uaTags, uaRaw:
+	default:
+		log.Fatalf("caddy ua_detail: unknown detail level %q", c.uaDetail)
+	}
+
+	for _, pattern := range conf.Bots {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			log.Fatalln("caddy bots:", err)
+		}
+		c.bots = append(c.bots, re)
+	}
+
+	for level, clrString := range conf.Levels {
+		c.Levels[level] = color.GenerateColorizer(clrString)
 	}
 
+	for code, clrString := range conf.StatusCodes {
+		c.StatusCodes[caddyStatus(code)] = color.GenerateColorizer(clrString)
+	}
+
+	for _, slow := range conf.Slow {
+		c.Slow = append(c.Slow, slowColor{
+			Over:      time.Duration(slow.Over * float64(time.Second)),
+			Colorizer: color.GenerateColorizer(slow.Color),
+		})
+	}
+	sort.Slice(c.Slow, func(i, j int) bool {
+		return c.Slow[i].Over < c.Slow[j].Over
+	})
+
+	c.clock = newClock(conf)
+
+	geo, err := newGeoIP(conf.GeoIPCountry, conf.GeoIPASN)
+	if err != nil {
+		log.Fatalln("caddy geoip:", err)
+	}
+	c.geoIP = geo
+
+	styles := make(map[string]color.Colorizer, len(conf.Styles))
+	for name, clrString := range conf.Styles {
+		styles[name] = color.GenerateColorizer(clrString)
+	}
+
+	layout, err := c.newLayout(conf.Layout, styles)
+	if err != nil {
+		log.Fatalln("caddy layout:", err)
+	}
+	c.layout = layout
+
 	return c
 }
 
-// parse attempts to unmarshal a log entry into a caddyLog struct.
-// It returns a pointer to that new struct and an error indicating
-// whether parsing was successful.
-func parse(in string) (*caddyLog, error) {
+// errNotCaddy is returned when JSON lacks the fields every caddy
+// log entry carries, or wasn't logged by one of caddy's loggers.
+var errNotCaddy = errors.New("not a caddy log entry")
+
+// caddyLoggers are the namespaces of caddy's loggers, such as
+// http.log.access, tls.obtain and admin.api. Other zap logs look
+// the same as caddy's, so entries from other loggers, or from none
+// (as with caddy's startup messages), are left to other formatters.
+var caddyLoggers = []string{"http", "tls", "admin", "pki", "events"}
+
+// parse attempts to unmarshal a caddy log entry. The line is
+// decoded once, and both the caddyLog and the entry's fields are
+// built from that. It returns an error if the line isn't a caddy
+// log entry.
+func parse(in string) (*caddyLog, map[string]any, error) {
 	// If unmarshalling fails, input wasn't a caddy JSON log entry
-	var cl *caddyLog
-	err := json.Unmarshal([]byte(in), &cl)
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(in), &fields); err != nil {
+		return nil, nil, err
+	}
+
+	// Every caddy log entry has these, whichever logger wrote it
+	for _, key := range []string{"level", "ts", "msg"} {
+		if _, ok := fields[key]; !ok {
+			return nil, nil, errNotCaddy
+		}
+	}
+
+	logger, _ := fields["logger"].(string)
+	namespace, _, _ := strings.Cut(logger, ".")
+	if !slices.Contains(caddyLoggers, namespace) {
+		return nil, nil, errNotCaddy
+	}
 
-	return cl, err
+	return newCaddyLog(fields), fields, nil
 }
@@ -0,0 +1,302 @@
+package caddy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/assistcontrol/muxytail/config"
+	"github.com/assistcontrol/muxytail/formatter"
+	termcolor "github.com/gookit/color"
+)
+
+func TestFormatOther(t *testing.T) {
+	clr := New(config.CaddyConfig{
+		Levels: map[string]string{"error": "#FF0000"},
+	})
+	ts := time.Unix(1664590340, 0).Format(formatter.TimeFormat)
+	red := termcolor.HEXStyle("#FF0000").Sprint
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		success  bool
+	}{
+		{
+			name:     "TLS info",
+			input:    `{"level":"info","ts":1664590340.26,"logger":"tls.obtain","msg":"acquiring lock","identifier":"example.com"}`,
+			expected: "[" + ts + "] INFO  tls.obtain: acquiring lock identifier=example.com",
+			success:  true,
+		},
+		{
+			name:     "Reverse proxy error",
+			input:    `{"level":"error","ts":1664590340.26,"logger":"http.handlers.reverse_proxy","msg":"aborting with incomplete response","upstream":"localhost:8080","duration":0.5,"error":"reading: context canceled"}`,
+			expected: "[" + ts + "] " + red("ERROR") + " http.handlers.reverse_proxy: " + red("aborting with incomplete response") + ` duration=0.5 error="reading: context canceled" upstream=localhost:8080`,
+			success:  true,
+		},
+		{
+			name:     "Nested fields",
+			input:    `{"level":"warn","ts":1664590340.26,"logger":"admin","msg":"x","config":{"a": [1, 2]}}`,
+			expected: "[" + ts + "] WARN  admin: x config={\"a\":[1,2]}",
+			success:  true,
+		},
+		{
+			name:     "Not a caddy log",
+			input:    `{"status":200}`,
+			expected: `{"status":200}`,
+			success:  false,
+		},
+		{
+			name:     "Another app's zap log",
+			input:    `{"level":"info","ts":1664590340.26,"logger":"myapp.db","msg":"connected"}`,
+			expected: `{"level":"info","ts":1664590340.26,"logger":"myapp.db","msg":"connected"}`,
+			success:  false,
+		},
+		{
+			name:     "No logger",
+			input:    `{"level":"info","ts":1664590340.26,"msg":"connected"}`,
+			expected: `{"level":"info","ts":1664590340.26,"msg":"connected"}`,
+			success:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, success := clr.Format(tt.input)
+			if success != tt.success {
+				t.Errorf("expected success %v, got %v", tt.success, success)
+			}
+			if result != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestFormatAccess(t *testing.T) {
+	ts := time.Unix(1664590340, 0).Format(formatter.TimeFormat)
+
+	tests := []struct {
+		name     string
+		conf     config.CaddyConfig
+		input    string
+		expected string
+	}{
+		{
+			name:     "Minimal",
+			input:    `{"level":"info","ts":1664590340.26,"logger":"http.log.access.log1","msg":"handled request","request":{"remote_ip":"192.0.2.1","proto":"HTTP/2.0","method":"GET","host":"example.com","uri":"/abg","headers":{"User-Agent":["curl/8.0.1"]}},"status":200}`,
+			expected: "192.0.2.1 [" + ts + "] example.com/abg (200) GET HTTP/2.0 [curl 8]",
+		},
+		{
+			name:     "Duration, size, TLS and user",
+			input:    `{"level":"info","ts":1664590340.26,"logger":"http.log.access.log1","msg":"handled request","request":{"remote_ip":"192.0.2.1","proto":"HTTP/2.0","method":"GET","host":"example.com","uri":"/abg","headers":{"User-Agent":["curl/8.0.1"]},"tls":{"resumed":true,"version":772,"cipher_suite":4865,"proto":"h2","server_name":"example.com"}},"user_id":"alice","duration":0.009557991,"size":3380,"status":200}`,
+			expected: "192.0.2.1 alice [" + ts + "] example.com/abg (200) 9.56ms 3.3KiB GET HTTP/2.0 TLS1.3 [curl 8]",
+		},
+		{
+			name:     "Raw sizes",
+			conf:     config.CaddyConfig{RawSizes: true},
+			input:    `{"level":"info","ts":1664590340.26,"logger":"http.log.access.log1","msg":"handled request","request":{"remote_ip":"192.0.2.1","proto":"HTTP/1.1","method":"GET","host":"example.com","uri":"/abg"},"size":3380,"status":200}`,
+			expected: "192.0.2.1 [" + ts + "] example.com/abg (200) 3380 GET HTTP/1.1 [-]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, success := New(tt.conf).Format(tt.input)
+			if !success {
+				t.Fatal("access log not recognized")
+			}
+			if result != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestColorizeDuration(t *testing.T) {
+	clr := New(config.CaddyConfig{
+		Duration: "#00FF00",
+		Slow: []config.SlowConfig{
+			{Over: 1, Color: "#FF0000"},
+			{Over: 0.5, Color: "#FFFF00"},
+		},
+	})
+
+	tests := []struct {
+		name     string
+		duration caddyDuration
+		expected string
+	}{
+		{"Fast", 0.1, termcolor.HEXStyle("#00FF00").Sprint("100ms")},
+		{"Slowish", 0.75, termcolor.HEXStyle("#FFFF00").Sprint("750ms")},
+		{"Slow", 2.5, termcolor.HEXStyle("#FF0000").Sprint("2.5s")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := clr.colorizeDuration(tt.duration); result != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestColorizeUA(t *testing.T) {
+	clr := New(config.CaddyConfig{
+		UA:   "#00FF00",
+		Bot:  "#808080",
+		Bots: []string{`^curl/`},
+	})
+
+	tests := []struct {
+		name     string
+		cUA      caddyUserAgent
+		expected string
+	}{
+		{
+			name:     "Browser",
+			cUA:      caddyUserAgent{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/58.0.3029.110 Safari/537.3"},
+			expected: termcolor.HEXStyle("#00FF00").Sprint("Chrome 58 │ Windows 10"),
+		},
+		{
+			name:     "Bot pattern",
+			cUA:      caddyUserAgent{"curl/8.0.1"},
+			expected: termcolor.HEXStyle("#808080").Sprint("BOT: curl 8"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := clr.colorizeUA(tt.cUA); result != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestColorizeStatusClasses(t *testing.T) {
+	style := func(hex string) func(...any) string {
+		return termcolor.HEXStyle(hex).Sprint
+	}
+
+	tests := []struct {
+		name     string
+		conf     config.CaddyConfig
+		status   caddyStatus
+		expected string
+	}{
+		{
+			name:     "Legacy keys: 3xx falls back to other",
+			conf:     config.CaddyConfig{StatusOK: "#00FF00", StatusError: "#FF0000", StatusOther: "#FFFF00"},
+			status:   302,
+			expected: style("#FFFF00")("302"),
+		},
+		{
+			name:     "Legacy keys: 5xx falls back to error",
+			conf:     config.CaddyConfig{StatusOK: "#00FF00", StatusError: "#FF0000", StatusOther: "#FFFF00"},
+			status:   502,
+			expected: style("#FF0000")("502"),
+		},
+		{
+			name:     "Class color: 1xx",
+			conf:     config.CaddyConfig{StatusOther: "#FFFF00", Status1xx: "#808080"},
+			status:   101,
+			expected: style("#808080")("101"),
+		},
+		{
+			name:     "Class color: 4xx",
+			conf:     config.CaddyConfig{StatusError: "#FF0000", Status4xx: "#FF8000"},
+			status:   403,
+			expected: style("#FF8000")("403"),
+		},
+		{
+			name:     "Class color: 5xx",
+			conf:     config.CaddyConfig{StatusError: "#FF0000", Status4xx: "#FF8000", Status5xx: "#FF00FF"},
+			status:   503,
+			expected: style("#FF00FF")("503"),
+		},
+		{
+			name:     "Per-code override",
+			conf:     config.CaddyConfig{Status4xx: "#FF8000", StatusCodes: map[int]string{429: "#FF00FF"}},
+			status:   429,
+			expected: style("#FF00FF")("429"),
+		},
+		{
+			name:     "Per-code override leaves class alone",
+			conf:     config.CaddyConfig{Status4xx: "#FF8000", StatusCodes: map[int]string{429: "#FF00FF"}},
+			status:   404,
+			expected: style("#FF8000")("404"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := New(tt.conf).colorizeStatus(tt.status); result != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestFormatEntry(t *testing.T) {
+	clr := New(config.CaddyConfig{})
+
+	e := formatter.NewEntry("caddy.log", `{"level":"warn","ts":1664590340.25,"logger":"tls","msg":"stapling failed","identifier":"example.com"}`)
+	if !clr.FormatEntry(e) {
+		t.Fatal("FormatEntry() failed")
+	}
+
+	if want := time.Unix(1664590340, 250e6); !e.Time.Equal(want) {
+		t.Errorf("Time = %v, want %v", e.Time, want)
+	}
+	if e.Level != "warn" {
+		t.Errorf("Level = %q, want %q", e.Level, "warn")
+	}
+	if e.Fields["identifier"] != "example.com" || e.Fields["logger"] != "tls" {
+		t.Errorf("Fields = %v", e.Fields)
+	}
+
+	// JSON that isn't a caddy log entry is left alone
+	e = formatter.NewEntry("caddy.log", `{"msg":"hi"}`)
+	if clr.FormatEntry(e) || e.Text != `{"msg":"hi"}` || e.Fields != nil {
+		t.Errorf("FormatEntry() modified non-caddy entry: %+v", e)
+	}
+}
+
+func TestFormatEntry_LayoutError(t *testing.T) {
+	clr := New(config.CaddyConfig{Layout: `{{.Req.TLS.Version}} {{url .URL}}`})
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		success  bool
+	}{
+		{
+			name:     "TLS request",
+			input:    `{"level":"info","ts":1664590340.26,"logger":"http.log.access","msg":"handled request","request":{"host":"example.com","uri":"/","tls":{"version":772}},"status":200}`,
+			expected: "772 example.com/",
+			success:  true,
+		},
+		{
+			// The layout can't be rendered without TLS, so the line
+			// is left to the next formatter
+			name:     "Plain HTTP request",
+			input:    `{"level":"info","ts":1664590340.26,"logger":"http.log.access","msg":"handled request","request":{"host":"example.com","uri":"/"},"status":200}`,
+			expected: `{"level":"info","ts":1664590340.26,"logger":"http.log.access","msg":"handled request","request":{"host":"example.com","uri":"/"},"status":200}`,
+			success:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := formatter.NewEntry("caddy.log", tt.input)
+			if success := clr.FormatEntry(e); success != tt.success {
+				t.Errorf("expected success %v, got %v", tt.success, success)
+			}
+			if e.Text != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, e.Text)
+			}
+		})
+	}
+}
@@ -91,7 +91,7 @@ func TestFormatLog(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			clr := New(tt.colorCfg)
-			result := clr.formatLog(tt.cLog)
+			result, _ := clr.formatLog(tt.cLog)
 			if result != tt.expected {
 				t.Errorf("expected %q, got %q", tt.expected, result)
 			}
@@ -171,7 +171,7 @@ func TestParse(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, err := parse(tt.input)
+			_, _, err := parse(tt.input)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("parse() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -0,0 +1,63 @@
+package caddy
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/assistcontrol/muxytail/config"
+	"github.com/assistcontrol/muxytail/formatter"
+)
+
+// struct clock renders caddy timestamps with formatter.FormatTime,
+// like those of other formatters. In relative mode, each timestamp
+// is followed by the time elapsed since the previous line of the
+// same source ("+0.350s"), which makes bursts easy to spot.
+type clock struct {
+	relative bool
+
+	mu   sync.Mutex           // Format is called from many goroutines
+	prev map[string]time.Time // by source
+}
+
+// newClock creates a clock from the caddy config.
+func newClock(conf config.CaddyConfig) *clock {
+	return &clock{
+		relative: conf.RelativeTime,
+		prev:     make(map[string]time.Time),
+	}
+}
+
+// clock.Format renders a timestamp of a line from source.
+func (c *clock) Format(source string, cTS caddyTimeStamp) string {
+	t := cTS.Time()
+	s := formatter.FormatTime(t)
+
+	// A missing timestamp is no reference point for the next line
+	if !c.relative || cTS == 0 {
+		return s
+	}
+
+	c.mu.Lock()
+	prev := c.prev[source]
+	c.prev[source] = t
+	c.mu.Unlock()
+
+	if prev.IsZero() {
+		return s
+	}
+
+	return s + " " + since(t.Sub(prev))
+}
+
+// since renders a duration as signed seconds with millisecond
+// precision (+0.350s). Lines can arrive slightly out of order, so
+// the difference may be negative.
+func since(d time.Duration) string {
+	s := strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+	if d >= 0 {
+		s = "+" + s
+	}
+
+	return s + "s"
+}
@@ -0,0 +1,78 @@
+package caddy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/assistcontrol/muxytail/config"
+	"github.com/assistcontrol/muxytail/formatter"
+)
+
+func TestClock_Format(t *testing.T) {
+	if err := formatter.SetTime("15:04:05.000", "UTC"); err != nil {
+		t.Fatal(err)
+	}
+	defer formatter.SetTime("", "")
+
+	tests := []struct {
+		name     string
+		conf     config.CaddyConfig
+		input    []caddyTimeStamp
+		sources  []string // of each input; "" if nil
+		expected []string
+	}{
+		{
+			name:     "Shared layout and zone",
+			conf:     config.CaddyConfig{},
+			input:    []caddyTimeStamp{1664590340.2631304},
+			expected: []string{"02:12:20.263"},
+		},
+		{
+			name:  "Relative",
+			conf:  config.CaddyConfig{RelativeTime: true},
+			input: []caddyTimeStamp{1664590340.25, 1664590340.6, 1664590340.59, 1664590342.6},
+			expected: []string{
+				"02:12:20.250",
+				"02:12:20.600 +0.350s",
+				"02:12:20.590 -0.010s",
+				"02:12:22.600 +2.010s",
+			},
+		},
+		{
+			name:    "Relative per source",
+			conf:    config.CaddyConfig{RelativeTime: true},
+			input:   []caddyTimeStamp{1664590340.25, 1664590340.5, 1664590341.25, 1664590342},
+			sources: []string{"a.log", "b.log", "a.log", "b.log"},
+			expected: []string{
+				"02:12:20.250",
+				"02:12:20.500",
+				"02:12:21.250 +1.000s",
+				"02:12:22.000 +1.500s",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newClock(tt.conf)
+
+			for i, ts := range tt.input {
+				var source string
+				if tt.sources != nil {
+					source = tt.sources[i]
+				}
+				if got := c.Format(source, ts); got != tt.expected[i] {
+					t.Errorf("Format(%v) = %q, want %q", ts, got, tt.expected[i])
+				}
+			}
+		})
+	}
+}
+
+func Test_caddyTimeStamp_Time(t *testing.T) {
+	got := caddyTimeStamp(1664590340.25).Time()
+	want := time.Date(2022, time.October, 1, 2, 12, 20, 250000000, time.UTC)
+	if !got.Equal(want) {
+		t.Errorf("caddyTimeStamp.Time() = %v, want %v", got, want)
+	}
+}
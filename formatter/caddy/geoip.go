@@ -0,0 +1,109 @@
+package caddy
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// geoCacheSize bounds the number of cached lookups. When it is
+// reached the cache is simply emptied; busy streams are dominated
+// by a small number of clients, so it soon refills with them.
+const geoCacheSize = 4096
+
+// struct geoIP annotates client IPs with a country code and ASN
+// from local MaxMind-format databases. Either database may be
+// absent. The country and ASN data may also live in one database,
+// in which case both paths point to the same file.
+type geoIP struct {
+	country, asn *maxminddb.Reader
+
+	mu    sync.Mutex // Annotate is called from many goroutines
+	cache map[string]string
+}
+
+// geoRecord holds the fields looked up from the databases. The
+// names follow the GeoIP2/GeoLite2 Country and ASN schemas.
+type geoRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	ASN uint `maxminddb:"autonomous_system_number"`
+}
+
+// newGeoIP opens the configured databases. It returns nil, and no
+// error, if neither is configured.
+func newGeoIP(countryPath, asnPath string) (*geoIP, error) {
+	if countryPath == "" && asnPath == "" {
+		return nil, nil
+	}
+
+	g := &geoIP{cache: make(map[string]string)}
+
+	var err error
+	if countryPath != "" {
+		if g.country, err = maxminddb.Open(countryPath); err != nil {
+			return nil, err
+		}
+	}
+	if asnPath != "" {
+		if g.asn, err = maxminddb.Open(asnPath); err != nil {
+			return nil, err
+		}
+	}
+
+	return g, nil
+}
+
+// geoIP.Annotate returns the country code and ASN of the supplied
+// IP ("US AS15169"), or "" if nothing is known about it. A nil
+// *geoIP annotates nothing.
+func (g *geoIP) Annotate(remote caddyRemoteIP) string {
+	if g == nil {
+		return ""
+	}
+
+	key := string(remote)
+
+	g.mu.Lock()
+	s, ok := g.cache[key]
+	g.mu.Unlock()
+	if ok {
+		return s
+	}
+
+	s = g.lookup(net.ParseIP(key))
+
+	g.mu.Lock()
+	if len(g.cache) >= geoCacheSize {
+		clear(g.cache)
+	}
+	g.cache[key] = s
+	g.mu.Unlock()
+
+	return s
+}
+
+// geoIP.lookup queries the databases for ip.
+func (g *geoIP) lookup(ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+
+	var parts []string
+
+	var rec geoRecord
+	if g.country != nil && g.country.Lookup(ip, &rec) == nil && rec.Country.ISOCode != "" {
+		parts = append(parts, rec.Country.ISOCode)
+	}
+
+	rec = geoRecord{}
+	if g.asn != nil && g.asn.Lookup(ip, &rec) == nil && rec.ASN != 0 {
+		parts = append(parts, "AS"+strconv.FormatUint(uint64(rec.ASN), 10))
+	}
+
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,76 @@
+package caddy
+
+import (
+	"testing"
+
+	"github.com/assistcontrol/muxytail/config"
+)
+
+// testdata/test.mmdb maps the documentation networks to made-up
+// countries and private-use ASNs, with country and ASN data in
+// the same database. It is written by testdata/mkmmdb.
+const testMMDB = "testdata/test.mmdb"
+
+func TestGeoIP_Annotate(t *testing.T) {
+	tests := []struct {
+		name    string
+		country string
+		asn     string
+		remote  caddyRemoteIP
+		want    string
+	}{
+		{"Country and ASN", testMMDB, testMMDB, "192.0.2.10", "US AS64496"},
+		{"Country only", testMMDB, "", "198.51.100.7", "DE"},
+		{"ASN only", "", testMMDB, "198.51.100.7", "AS64500"},
+		{"IPv6", testMMDB, testMMDB, "2001:db8::1", "JP AS64510"},
+		{"Unknown network", testMMDB, testMMDB, "203.0.113.1", ""},
+		{"Not an IP", testMMDB, testMMDB, "bogus", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := newGeoIP(tt.country, tt.asn)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Second call is served from the cache
+			for range 2 {
+				if got := g.Annotate(tt.remote); got != tt.want {
+					t.Errorf("Annotate(%q) = %q, want %q", tt.remote, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestGeoIP_Disabled(t *testing.T) {
+	g, err := newGeoIP("", "")
+	if err != nil || g != nil {
+		t.Fatalf("newGeoIP() = %v, %v; want nil, nil", g, err)
+	}
+	if got := g.Annotate("192.0.2.10"); got != "" {
+		t.Errorf("Annotate() = %q, want \"\"", got)
+	}
+}
+
+func TestGeoIP_MissingDatabase(t *testing.T) {
+	if _, err := newGeoIP("testdata/missing.mmdb", ""); err == nil {
+		t.Error("expected an error for a missing database")
+	}
+}
+
+func TestFormatAccess_GeoIP(t *testing.T) {
+	clr := New(config.CaddyConfig{
+		Layout:       `{{.Req.Remote}} {{geo .Req.Remote}} {{.URL}}`,
+		GeoIPCountry: testMMDB,
+		GeoIPASN:     testMMDB,
+	})
+
+	input := `{"level":"info","ts":1,"logger":"http.log.access","msg":"handled request","request":{"remote_ip":"192.0.2.10","host":"example.com","uri":"/"},"status":200}`
+	expected := "192.0.2.10 US AS64496 example.com/"
+
+	if result, _ := clr.Format(input); result != expected {
+		t.Errorf("expected %q, got %q", expected, result)
+	}
+}
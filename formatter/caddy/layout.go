@@ -0,0 +1,81 @@
+package caddy
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/assistcontrol/muxytail/color"
+)
+
+// defaultLayout renders access logs as
+// host geo user [ts] url (status) duration size read method proto tls upstream referer [UA]
+const defaultLayout = `{{host .Req.Remote}} {{geo .Req.Remote}} {{user .UserID}} ` +
+	`{{bracket "["}}{{time .TS}}{{bracket "]"}} {{url .URL}} ({{status .Status}}) ` +
+	`{{duration .Duration}} {{size .Size}} {{read .BytesRead}} {{.Req.Method}} {{.Req.Proto}} ` +
+	`{{.Req.TLS}} {{upstream .Upstream}} {{.Req.Headers.Referer}} {{bracket "["}}{{ua .Req.Headers.UA}}{{bracket "]"}}`
+
+// layoutSample returns an access log entry for checking layouts,
+// with every pointer field set.
+func layoutSample() *caddyLog {
+	return &caddyLog{
+		Req: caddyReq{TLS: &caddyTLS{}},
+	}
+}
+
+// newLayout parses an access log layout template. The template is
+// executed with a *caddyLog, and can call the colorizer functions
+// (bracket, duration, geo, host, logger, read, size, status, ua,
+// upstream, url, user), time, which renders a timestamp per the time settings, and
+// style, which applies one of the named styles.
+func (clr *colorizer) newLayout(layout string, styles map[string]color.Colorizer) (*template.Template, error) {
+	if layout == "" {
+		layout = defaultLayout
+	}
+
+	funcs := template.FuncMap{
+		"bracket":  clr.Bracket,
+		"duration": clr.colorizeDuration,
+		"geo":      clr.colorizeGeo,
+		"host":     clr.Host,
+		"logger":   clr.Logger,
+		"size": func(s caddySize) string {
+			return clr.Size(clr.size(s))
+		},
+		"read":   clr.colorizeRead,
+		"status": clr.colorizeStatus,
+		"time": func(cTS caddyTimeStamp) string {
+			return clr.clock.Format("", cTS)
+		},
+		"ua":       clr.colorizeUA,
+		"upstream": clr.colorizeUpstream,
+		"url":      clr.URL,
+		"user":     clr.User,
+		"style": func(name string, args ...any) (string, error) {
+			c, ok := styles[name]
+			if !ok {
+				return "", fmt.Errorf("unknown style %q", name)
+			}
+			return c(args...), nil
+		},
+	}
+
+	tmpl, err := template.New("layout").Funcs(funcs).Parse(layout)
+	if err != nil {
+		return nil, err
+	}
+
+	// Catch references to unknown fields and styles up front, rather
+	// than on every log line. The sample has every optional part
+	// filled in, so that layouts reading them can be checked.
+	// A clone is checked, as an executed template can't be cloned.
+	check, err := tmpl.Clone()
+	if err != nil {
+		return nil, err
+	}
+	if err = check.Execute(&strings.Builder{}, layoutSample()); err != nil {
+		return nil, err
+	}
+
+	return tmpl, nil
+}
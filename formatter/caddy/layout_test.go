@@ -0,0 +1,97 @@
+package caddy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/assistcontrol/muxytail/color"
+	"github.com/assistcontrol/muxytail/config"
+	termcolor "github.com/gookit/color"
+)
+
+func TestNewLayout(t *testing.T) {
+	styles := map[string]color.Colorizer{
+		"method": color.GenerateColorizer("#FF00FF"),
+	}
+
+	cLog := &caddyLog{
+		Req: caddyReq{
+			Host:   "example.com",
+			Method: "POST",
+			URI:    "/api",
+			TLS:    &caddyTLS{ServerName: "example.com"},
+		},
+		BytesRead: 1536,
+		RespHeaders: caddyRespHeaders{
+			ContentType: caddyHeaderValue{"application/json"},
+			Server:      caddyHeaderValue{"nginx"},
+		},
+		Size:     2048,
+		Status:   201,
+		Upstream: "10.0.0.5:8080",
+	}
+
+	tests := []struct {
+		name     string
+		layout   string
+		expected string
+		wantErr  bool
+	}{
+		{
+			name:     "Reordered fields",
+			layout:   `{{status .Status}} {{.Req.Method}} {{url .URL}} {{size .Size}}`,
+			expected: "201 POST example.com/api 2.0KiB",
+		},
+		{
+			name:     "Named style",
+			layout:   `{{style "method" .Req.Method}} {{.URL}}`,
+			expected: termcolor.HEXStyle("#FF00FF").Sprint("POST") + " example.com/api",
+		},
+		{
+			name:     "Request body and upstream",
+			layout:   `{{read .BytesRead}} {{upstream .Upstream}} {{.RespHeaders.Server}} {{.RespHeaders.ContentType}}`,
+			expected: "in:1.5KiB →10.0.0.5:8080 nginx application/json",
+		},
+		{
+			name:     "TLS fields",
+			layout:   `{{with .Req.TLS}}{{.ServerName}}{{end}} {{.Req.TLS.ServerName}}`,
+			expected: "example.com example.com",
+		},
+		{
+			name:    "Unknown style",
+			layout:  `{{style "nope" .Req.Method}}`,
+			wantErr: true,
+		},
+		{
+			name:    "Unknown field",
+			layout:  `{{.Nope}}`,
+			wantErr: true,
+		},
+		{
+			name:    "Syntax error",
+			layout:  `{{.Status`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clr := New(config.CaddyConfig{})
+			tmpl, err := clr.newLayout(tt.layout, styles)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newLayout() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			var b strings.Builder
+			if err = tmpl.Execute(&b, cLog); err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, b.String())
+			}
+		})
+	}
+}
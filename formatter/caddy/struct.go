@@ -1,11 +1,18 @@
 package caddy
 
 import (
+	"crypto/tls"
+	"encoding/json"
 	"fmt"
+	"math"
 	"net"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/assistcontrol/muxytail/formatter"
 	"github.com/mileusna/useragent"
 )
 
@@ -13,25 +20,151 @@ import (
 // CADDY LOG DATA STRUCTURE
 //
 
-// struct caddyLog is holds a single caddy log entry.
+// struct caddyLog is holds a single caddy log entry. It is built
+// by newCaddyLog from the decoded JSON; the JSON names of the
+// fields are given in their comments.
 type caddyLog struct {
-	Req    caddyReq       `json:"request"`
-	Status caddyStatus    `json:"status"`
-	TS     caddyTimeStamp `json:"ts"`
+	Level       string           // level
+	Logger      string           // logger
+	Msg         string           // msg
+	BytesRead   caddySize        // bytes_read
+	Duration    caddyDuration    // duration
+	Req         caddyReq         // request
+	RespHeaders caddyRespHeaders // resp_headers
+	Size        caddySize        // size
+	Status      caddyStatus      // status
+	TS          caddyTimeStamp   // ts
+	UserID      string           // user_id
+
+	// Upstream is the backend that served a proxied request. Caddy
+	// doesn't log it by default; add it with
+	// log_append upstream {http.reverse_proxy.upstream.hostport}.
+	Upstream string // upstream
+
+	// Fields holds every field other than level, ts, logger
+	// and msg, for rendering non-access logs.
+	Fields caddyFields
+
+	// source is the file or command the entry was read from, which
+	// relative timestamps are measured within.
+	source string
 }
 
 type caddyReq struct {
-	Headers caddyHeaders  `json:"headers"`
-	Host    string        `json:"host"`
-	Method  string        `json:"method"`
-	Proto   string        `json:"proto"`
-	Remote  caddyRemoteIP `json:"remote_ip"`
-	URI     string        `json:"uri"`
+	Headers caddyHeaders  // headers
+	Host    string        // host
+	Method  string        // method
+	Proto   string        // proto
+	Remote  caddyRemoteIP // remote_ip
+	TLS     *caddyTLS     // tls
+	URI     string        // uri
 }
 
 type caddyHeaders struct {
-	Referer caddyReferer   `json:"Referer"`
-	UA      caddyUserAgent `json:"User-Agent"`
+	Referer caddyReferer   // Referer
+	UA      caddyUserAgent // User-Agent
+}
+
+type caddyRespHeaders struct {
+	ContentType caddyHeaderValue // Content-Type
+	Server      caddyHeaderValue // Server
+}
+
+// newCaddyLog builds a caddyLog from a decoded log entry. Fields of
+// the wrong type are left empty.
+func newCaddyLog(fields map[string]any) *caddyLog {
+	req := object(fields, "request")
+	headers := object(req, "headers")
+	respHeaders := object(fields, "resp_headers")
+
+	cl := &caddyLog{
+		Level:     str(fields, "level"),
+		Logger:    str(fields, "logger"),
+		Msg:       str(fields, "msg"),
+		BytesRead: caddySize(number(fields, "bytes_read")),
+		Duration:  caddyDuration(number(fields, "duration")),
+		Req: caddyReq{
+			Headers: caddyHeaders{
+				Referer: strs(headers, "Referer"),
+				UA:      strs(headers, "User-Agent"),
+			},
+			Host:   str(req, "host"),
+			Method: str(req, "method"),
+			Proto:  str(req, "proto"),
+			Remote: caddyRemoteIP(str(req, "remote_ip")),
+			URI:    str(req, "uri"),
+		},
+		RespHeaders: caddyRespHeaders{
+			ContentType: strs(respHeaders, "Content-Type"),
+			Server:      strs(respHeaders, "Server"),
+		},
+		Size:     caddySize(number(fields, "size")),
+		Status:   caddyStatus(number(fields, "status")),
+		TS:       caddyTimeStamp(number(fields, "ts")),
+		UserID:   str(fields, "user_id"),
+		Upstream: str(fields, "upstream"),
+	}
+
+	if tls := object(req, "tls"); tls != nil {
+		resumed, _ := tls["resumed"].(bool)
+		cl.Req.TLS = &caddyTLS{
+			CipherSuite: uint16(number(tls, "cipher_suite")),
+			Proto:       str(tls, "proto"),
+			Resumed:     resumed,
+			ServerName:  str(tls, "server_name"),
+			Version:     uint16(number(tls, "version")),
+		}
+	}
+
+	cl.Fields = make(caddyFields, len(fields))
+	for k, v := range fields {
+		switch k {
+		case "level", "ts", "logger", "msg":
+		default:
+			cl.Fields[k] = v
+		}
+	}
+
+	return cl
+}
+
+// object returns m[key] if it is a JSON object, or nil.
+func object(m map[string]any, key string) map[string]any {
+	v, _ := m[key].(map[string]any)
+	return v
+}
+
+// str returns m[key] if it is a string, or "".
+func str(m map[string]any, key string) string {
+	v, _ := m[key].(string)
+	return v
+}
+
+// number returns m[key] if it is a number, or 0.
+func number(m map[string]any, key string) float64 {
+	v, _ := m[key].(float64)
+	return v
+}
+
+// strs returns the strings of m[key] if it is an array, as caddy
+// logs headers.
+func strs(m map[string]any, key string) []string {
+	list, _ := m[key].([]any)
+
+	var out []string
+	for _, v := range list {
+		if s, ok := v.(string); ok {
+			out = append(out, s)
+		}
+	}
+
+	return out
+}
+
+// caddyLog.IsAccess reports whether the entry came from an access
+// logger, as opposed to caddy's own logs (errors, TLS, admin, ...).
+func (cL *caddyLog) IsAccess() bool {
+	return strings.HasPrefix(cL.Logger, "http.log.access")
 }
 
 // caddyLog.URL constructs a request path (foo.com/bar.html)
@@ -43,6 +176,86 @@ func (cL *caddyLog) URL() string {
 // CADDY LOG FIELD TYPES
 //
 
+// ---
+// caddyFields holds the remaining fields of a log entry, as decoded
+type caddyFields map[string]any
+
+// caddyFields.String renders the fields as key=value pairs, sorted
+// by key. String values are printed bare unless they contain
+// spaces; anything else is printed as compact JSON.
+func (fields caddyFields) String() string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+fieldValue(fields[k]))
+	}
+
+	return strings.Join(pairs, " ")
+}
+
+// fieldValue renders a single decoded JSON value for
+// caddyFields.String.
+func fieldValue(v any) string {
+	if s, ok := v.(string); ok {
+		if s == "" || strings.ContainsAny(s, " \t\n\"") {
+			return strconv.Quote(s)
+		}
+		return s
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+
+	return string(b)
+}
+
+// ---
+// caddyDuration is the time taken to handle the request, in seconds
+type caddyDuration float64
+
+// caddyDuration.Duration converts the duration to a time.Duration.
+func (cD caddyDuration) Duration() time.Duration {
+	return time.Duration(float64(cD) * float64(time.Second))
+}
+
+// caddyDuration.String rounds the duration to a legible precision
+// (950µs, 9.56ms, 1.234s). A zero duration renders as "".
+func (cD caddyDuration) String() string {
+	d := cD.Duration()
+
+	switch {
+	case d == 0:
+		return ""
+	case d < time.Millisecond:
+		return d.Round(time.Microsecond).String()
+	case d < time.Second:
+		return d.Round(10 * time.Microsecond).String()
+	default:
+		return d.Round(time.Millisecond).String()
+	}
+}
+
+// ---
+// caddyHeaderValue is a header that is only rendered by its first
+// value
+type caddyHeaderValue []string
+
+// caddyHeaderValue.String returns the first value of the header.
+func (h caddyHeaderValue) String() string {
+	if len(h) == 0 {
+		return ""
+	}
+
+	return h[0]
+}
+
 // ---
 // caddyReferer is the client-supplied referer URL
 type caddyReferer []string
@@ -70,52 +283,175 @@ func (cR caddyRemoteIP) String() string {
 	return remote
 }
 
+// ---
+// caddySize is a byte count (response size or request body size)
+type caddySize int64
+
+// sizeUnits are the binary prefixes used by caddySize.String.
+const sizeUnits = "KMGTPE"
+
+// caddySize.String renders the size in human-readable binary units
+// (512B, 3.3KiB, 1.2MiB). A zero size renders as "".
+func (cS caddySize) String() string {
+	if cS == 0 {
+		return ""
+	}
+	if cS < 1024 {
+		return strconv.FormatInt(int64(cS), 10) + "B"
+	}
+
+	size, unit := float64(cS)/1024, 0
+	for size >= 1024 && unit < len(sizeUnits)-1 {
+		size /= 1024
+		unit++
+	}
+
+	return strconv.FormatFloat(size, 'f', 1, 64) + sizeUnits[unit:unit+1] + "iB"
+}
+
+// caddySize.Bytes renders the size as a plain byte count. A zero
+// size renders as "".
+func (cS caddySize) Bytes() string {
+	if cS == 0 {
+		return ""
+	}
+
+	return strconv.FormatInt(int64(cS), 10)
+}
+
 // ---
 // caddyStatus is the HTTP status code (200).
 type caddyStatus int
 
+// ---
+// caddyTLS describes the TLS connection of the request, if any
+type caddyTLS struct {
+	CipherSuite uint16 `json:"cipher_suite"`
+	Proto       string `json:"proto"`
+	Resumed     bool   `json:"resumed"`
+	ServerName  string `json:"server_name"`
+	Version     uint16 `json:"version"`
+}
+
+// caddyTLS.String returns the TLS version (TLS1.3), or "" if the
+// request wasn't made over TLS.
+func (cT *caddyTLS) String() string {
+	if cT == nil || cT.Version == 0 {
+		return ""
+	}
+
+	return strings.ReplaceAll(tls.VersionName(cT.Version), " ", "")
+}
+
 // ---
 // caddyTimeStamp is the timestamp of the request
 type caddyTimeStamp float64
 
-// timeFormat is the format string for displayed date/times.
-const timeFormat = "2/Jan 15:04:05"
+// caddyTimeStamp.Time converts the TS to a time.Time, keeping the
+// fractional seconds. A float64 holds epoch seconds to well under a
+// microsecond, so the fraction is rounded to the microsecond.
+func (cTS caddyTimeStamp) Time() time.Time {
+	sec, frac := math.Modf(float64(cTS))
+	usec := math.Round(frac * 1e6)
+	return time.Unix(int64(sec), int64(usec)*int64(time.Microsecond))
+}
 
-// caddyTimeStamp.String formats the TS using const timeFormat.
+// caddyTimeStamp.String formats the TS using formatter.FormatTime.
 func (cTS caddyTimeStamp) String() string {
-	return time.Unix(int64(cTS), 0).Format(timeFormat)
+	return formatter.FormatTime(cTS.Time())
 }
 
 // ---
 // caddyUserAgent holds the client UA
 type caddyUserAgent []string
 
+// User agent detail levels for caddyUserAgent.Render
+const (
+	uaShort = "short" // Chrome 58 │ Windows 10
+	uaFull  = "full"  // Chrome 58.0.3029.110 │ Windows 10.0 │ desktop
+	uaTags  = "tags"  // chrome windows desktop
+	uaRaw   = "raw"   // the UA string itself
+)
+
 // caddyUserAgent.String parses the longest UA in the form
 // "browserName browserMajor | OSName OSMajor"
 func (cUA caddyUserAgent) String() string {
+	s, _ := cUA.Render(uaShort, nil)
+	return s
+}
+
+// caddyUserAgent.Render parses the longest UA and renders it at the
+// given detail level. A UA matching any of bots is treated as a bot
+// regardless of what the parser detected. Render returns the
+// rendered UA and whether it is a bot.
+func (cUA caddyUserAgent) Render(detail string, bots []*regexp.Regexp) (string, bool) {
 	chosenUA := longest(cUA)
 
 	if len(chosenUA) == 0 {
-		return "-"
+		return "-", false
 	}
 
 	ua := useragent.Parse(chosenUA)
+	for _, re := range bots {
+		if re.MatchString(chosenUA) {
+			ua.Bot = true
+			break
+		}
+	}
 
 	var bot string
 	if ua.Bot {
 		bot = "BOT:"
 	}
 
-	s := fmt.Sprintln(bot, ua.Name, major(ua.Version), "│", ua.OS, major(ua.OSVersion))
-	s = strings.TrimSpace(s)
-	s = strings.TrimSuffix(s, " │") // If OS is empty
-	return s
+	var s string
+	switch detail {
+	case uaRaw:
+		s = chosenUA
+	case uaFull:
+		dev := device(ua)
+		if ua.Bot {
+			dev = "" // Already marked
+		}
+		s = fmt.Sprintln(bot, ua.Name, ua.Version, "│", ua.OS, ua.OSVersion, "│", dev)
+	case uaTags:
+		tags := []string{device(ua), ua.Name, ua.OS}
+		for i, tag := range tags {
+			tags[i] = strings.ReplaceAll(strings.ToLower(tag), " ", "-")
+		}
+		s = fmt.Sprintln(tags[1], tags[2], tags[0])
+	default:
+		s = fmt.Sprintln(bot, ua.Name, major(ua.Version), "│", ua.OS, major(ua.OSVersion))
+	}
+
+	s = whiteSpaceRE.ReplaceAllString(strings.TrimSpace(s), " ")
+	s = strings.ReplaceAll(s, "│ │", "│") // If OS is empty
+	s = strings.TrimSuffix(s, " │")       // If OS or device is empty
+
+	return s, ua.Bot
 }
 
 //
 // HELPER FUNCTIONS
 //
 
+// device returns the device type of a parsed UA: bot, mobile,
+// tablet or desktop, or "" if unknown.
+func device(ua useragent.UserAgent) string {
+	switch {
+	case ua.Bot:
+		return "bot"
+	case ua.Tablet:
+		return "tablet"
+	case ua.Mobile:
+		return "mobile"
+	case ua.Desktop:
+		return "desktop"
+	}
+
+	return ""
+}
+
 // major returns the major only from a semver version string
 func major(s string) string {
 	major, _, _ := strings.Cut(s, ".")
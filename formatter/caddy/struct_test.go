@@ -1,6 +1,9 @@
 package caddy
 
-import "testing"
+import (
+	"regexp"
+	"testing"
+)
 
 func Test_caddyUserAgent_String(t *testing.T) {
 	tests := []struct {
@@ -119,3 +122,100 @@ func Test_longest(t *testing.T) {
 		})
 	}
 }
+
+func Test_caddySize_String(t *testing.T) {
+	tests := []struct {
+		name  string
+		input caddySize
+		want  string
+	}{
+		{"Zero", 0, ""},
+		{"Bytes", 512, "512B"},
+		{"Kibibytes", 3380, "3.3KiB"},
+		{"Mebibytes", 5 * 1024 * 1024, "5.0MiB"},
+		{"Gibibytes", 3 * 1024 * 1024 * 1024 / 2, "1.5GiB"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.input.String(); got != tt.want {
+				t.Errorf("caddySize.String() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_caddyDuration_String(t *testing.T) {
+	tests := []struct {
+		name  string
+		input caddyDuration
+		want  string
+	}{
+		{"Zero", 0, ""},
+		{"Microseconds", 0.000950123, "950µs"},
+		{"Milliseconds", 0.009557991, "9.56ms"},
+		{"Seconds", 1.23456789, "1.235s"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.input.String(); got != tt.want {
+				t.Errorf("caddyDuration.String() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_caddyTLS_String(t *testing.T) {
+	tests := []struct {
+		name  string
+		input *caddyTLS
+		want  string
+	}{
+		{"No TLS", nil, ""},
+		{"TLS 1.2", &caddyTLS{Version: 771}, "TLS1.2"},
+		{"TLS 1.3", &caddyTLS{Version: 772}, "TLS1.3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.input.String(); got != tt.want {
+				t.Errorf("caddyTLS.String() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_caddyUserAgent_Render(t *testing.T) {
+	const (
+		chrome    = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/58.0.3029.110 Safari/537.3"
+		iphone    = "Mozilla/5.0 (iPhone; CPU iPhone OS 16_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.5 Mobile/15E148 Safari/604.1"
+		googlebot = "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"
+		scraper   = "python-requests/2.31.0"
+	)
+	bots := []*regexp.Regexp{regexp.MustCompile(`python-requests`)}
+
+	tests := []struct {
+		name    string
+		cUA     caddyUserAgent
+		detail  string
+		want    string
+		wantBot bool
+	}{
+		{"Short", caddyUserAgent{chrome}, uaShort, "Chrome 58 │ Windows 10", false},
+		{"Full", caddyUserAgent{chrome}, uaFull, "Chrome 58.0.3029.110 │ Windows 10.0 │ desktop", false},
+		{"Tags", caddyUserAgent{chrome}, uaTags, "chrome windows desktop", false},
+		{"Raw", caddyUserAgent{scraper}, uaRaw, scraper, true},
+		{"Mobile full", caddyUserAgent{iphone}, uaFull, "Safari 16.5 │ iOS 16.5 │ mobile", false},
+		{"Mobile tags", caddyUserAgent{iphone}, uaTags, "safari ios mobile", false},
+		{"Detected bot", caddyUserAgent{googlebot}, uaFull, "BOT: Googlebot 2.1", true},
+		{"Detected bot tags", caddyUserAgent{googlebot}, uaTags, "googlebot bot", true},
+		{"Pattern bot", caddyUserAgent{scraper}, uaShort, "BOT: python-requests 2", true},
+		{"Empty", caddyUserAgent{}, uaFull, "-", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, bot := tt.cUA.Render(tt.detail, bots)
+			if got != tt.want || bot != tt.wantBot {
+				t.Errorf("caddyUserAgent.Render() = %q, %v, want %q, %v", got, bot, tt.want, tt.wantBot)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+// Command mkmmdb writes the GeoIP test fixture, ../test.mmdb. It maps
+// the documentation networks to made-up countries and private-use
+// ASNs, with country and ASN data in one database, as in GeoIP2/
+// GeoLite2. It is a module of its own so that muxytail doesn't
+// depend on the writer. To regenerate the fixture:
+//
+//	cd formatter/caddy/testdata/mkmmdb && go run . ../test.mmdb
+package main
+
+import (
+	"log"
+	"net"
+	"os"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// buildEpoch is fixed so that the fixture is reproducible.
+const buildEpoch = 1704067200 // 2024-01-01
+
+func main() {
+	if len(os.Args) != 2 {
+		log.Fatalln("usage: mkmmdb output.mmdb")
+	}
+
+	w, err := mmdbwriter.New(mmdbwriter.Options{
+		DatabaseType:            "muxytail-Test",
+		Description:             map[string]string{"en": "muxytail GeoIP/ASN test fixture"},
+		RecordSize:              24,
+		IncludeReservedNetworks: true,
+		BuildEpoch:              buildEpoch,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	networks := []struct {
+		cidr, country string
+		asn           uint32
+	}{
+		{"192.0.2.0/24", "US", 64496},
+		{"198.51.100.0/24", "DE", 64500},
+		{"2001:db8::/32", "JP", 64510},
+	}
+	for _, n := range networks {
+		_, network, err := net.ParseCIDR(n.cidr)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		err = w.Insert(network, mmdbtype.Map{
+			"country":                  mmdbtype.Map{"iso_code": mmdbtype.String(n.country)},
+			"autonomous_system_number": mmdbtype.Uint32(n.asn),
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	f, err := os.Create(os.Args[1])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err = w.WriteTo(f); err != nil {
+		log.Fatal(err)
+	}
+	if err = f.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
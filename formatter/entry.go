@@ -0,0 +1,50 @@
+package formatter
+
+import "time"
+
+// struct Entry is a single log entry on its way through muxytail.
+// Formatters that understand the entry's structure fill in the
+// parsed fields alongside the rendered text, so that later stages
+// can work with the data rather than the text.
+type Entry struct {
+	Source string    // file path or command the entry came from
+	Raw    string    // text as it was read
+	Time   time.Time // zero if unknown
+	Level  string    // debug, info, notice, warn, error, ...; "" if unknown
+	Fields map[string]any
+	Text   string // rendered text; starts out as Raw
+	Drop   bool   // set by formatters to discard the entry
+}
+
+// NewEntry returns an unformatted entry for a line read from source.
+func NewEntry(source, raw string) *Entry {
+	return &Entry{
+		Source: source,
+		Raw:    raw,
+		Text:   raw,
+	}
+}
+
+// EntryFormatter is implemented by formatters that can fill in an
+// Entry's structured data. FormatEntry formats e.Text, and returns
+// a boolean indicating whether formatting succeeded. On failure, e
+// is left untouched.
+type EntryFormatter interface {
+	Formatter
+	FormatEntry(e *Entry) bool
+}
+
+// FormatEntry formats e with f. Formatters that aren't
+// EntryFormatters are adapted: their Format is applied to e.Text.
+func FormatEntry(f Formatter, e *Entry) bool {
+	if ef, ok := f.(EntryFormatter); ok {
+		return ef.FormatEntry(e)
+	}
+
+	out, ok := f.Format(e.Text)
+	if ok {
+		e.Text = out
+	}
+
+	return ok
+}
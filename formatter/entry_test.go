@@ -0,0 +1,83 @@
+package formatter
+
+import (
+	"strings"
+	"testing"
+)
+
+// upper is a plain Formatter that upper-cases lines starting with "x".
+type upper struct{}
+
+func (upper) Format(in string) (string, bool) {
+	if !strings.HasPrefix(in, "x") {
+		return in, false
+	}
+
+	return strings.ToUpper(in), true
+}
+
+// leveler is an EntryFormatter that sets the level of every entry.
+type leveler struct{}
+
+func (leveler) Format(in string) (string, bool) { return in, true }
+
+func (leveler) FormatEntry(e *Entry) bool {
+	e.Level = "info"
+	e.Text = "[info] " + e.Text
+
+	return true
+}
+
+func TestFormatEntry(t *testing.T) {
+	tests := []struct {
+		name      string
+		formatter Formatter
+		input     string
+		expected  string
+		level     string
+		success   bool
+	}{
+		{
+			name:      "Adapted formatter",
+			formatter: upper{},
+			input:     "xyz",
+			expected:  "XYZ",
+			success:   true,
+		},
+		{
+			name:      "Adapted formatter failure",
+			formatter: upper{},
+			input:     "abc",
+			expected:  "abc",
+			success:   false,
+		},
+		{
+			name:      "Entry formatter",
+			formatter: leveler{},
+			input:     "abc",
+			expected:  "[info] abc",
+			level:     "info",
+			success:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewEntry("test", tt.input)
+			ok := FormatEntry(tt.formatter, e)
+
+			if ok != tt.success {
+				t.Errorf("FormatEntry() success = %v, want %v", ok, tt.success)
+			}
+			if e.Text != tt.expected {
+				t.Errorf("FormatEntry() text = %q, want %q", e.Text, tt.expected)
+			}
+			if e.Level != tt.level {
+				t.Errorf("FormatEntry() level = %q, want %q", e.Level, tt.level)
+			}
+			if e.Raw != tt.input || e.Source != "test" {
+				t.Errorf("FormatEntry() changed raw or source: %+v", e)
+			}
+		})
+	}
+}
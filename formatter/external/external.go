@@ -0,0 +1,376 @@
+// Pipe log entries through an external process
+package external
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/assistcontrol/muxytail/config"
+	"github.com/assistcontrol/muxytail/formatter"
+)
+
+// Protocols spoken with the external process.
+const (
+	// ProtocolLine sends each entry as a line, and reads back a
+	// line. An empty line declines the entry. Multi-line entries
+	// can't be sent, and are declined.
+	ProtocolLine = "line"
+
+	// ProtocolJSON sends each entry as a JSON request on a line,
+	// and reads back a JSON response on a line. A response without
+	// a text field (such as {} or null) declines the entry.
+	ProtocolJSON = "json"
+)
+
+// defaultTimeout is how long to wait for a response if the config
+// doesn't say.
+const defaultTimeout = time.Second
+
+// restartDelay is the least time between starts of the process, so
+// that a command that fails straight away isn't restarted for every
+// line.
+const restartDelay = time.Second
+
+// maxLine is the longest response line read from the process.
+const maxLine = 1024 * 1024
+
+// logInterval is the least time between logged timeouts, so that a
+// struggling process doesn't flood the display.
+const logInterval = time.Minute
+
+// struct request is a json protocol request.
+type request struct {
+	Source string `json:"source"`
+	Line   string `json:"line"`
+}
+
+// struct response is a json protocol response. Level and Fields
+// are optional, and fill in the entry's own.
+type response struct {
+	Text   *string        `json:"text"`
+	Level  string         `json:"level"`
+	Fields map[string]any `json:"fields"`
+}
+
+// struct external runs entries through the process. Requests are
+// pipelined: each is queued for writing as soon as it is made, and
+// responses are matched to requests in order. The process is started on the
+// first request, and restarted if it exits or stops responding.
+type external struct {
+	command      string
+	protocol     string
+	timeout      time.Duration
+	restartDelay time.Duration
+
+	mu       sync.Mutex
+	proc     *process
+	started  time.Time
+	timeouts int // since the last one was logged
+	loggedAt time.Time
+}
+
+// struct process is a running instance of the command. Its fields
+// are guarded by external.mu. Requests are written to stdin by a
+// goroutine of their own, so that a process that stops reading
+// blocks only that goroutine, and not ext.mu.
+type process struct {
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	pending []chan string // awaiting responses, oldest first
+	queue   []string      // requests not yet written, oldest first
+	wake    chan struct{} // signals the writer that there is a queue
+	done    chan struct{} // closed when the process is stopped
+	exited  bool          // stdout has closed
+}
+
+// process.fail closes the channels of the requests awaiting
+// responses, which will never come.
+func (proc *process) fail() {
+	for _, reply := range proc.pending {
+		close(reply)
+	}
+	proc.pending = nil
+}
+
+// Format sends a line to the process and returns its response, and
+// a boolean indicating whether the process formatted the line. If
+// bool is false, the process declined, failed or timed out.
+func (ext *external) Format(in string) (string, bool) {
+	e := formatter.NewEntry("", in)
+	ok := ext.FormatEntry(e)
+
+	return e.Text, ok
+}
+
+// FormatEntry is Format for a formatter.Entry. With the json
+// protocol, the process may also fill in the entry's level and
+// fields.
+func (ext *external) FormatEntry(e *formatter.Entry) bool {
+	switch ext.protocol {
+	case ProtocolJSON:
+		req, err := json.Marshal(request{Source: e.Source, Line: e.Text})
+		if err != nil {
+			return false
+		}
+
+		out, ok := ext.roundTrip(string(req))
+		if !ok {
+			return false
+		}
+
+		var resp response
+		if err = json.Unmarshal([]byte(out), &resp); err != nil {
+			log.Printf("%s: bad response: %v", ext.command, err)
+			return false
+		}
+		if resp.Text == nil {
+			return false // Declined
+		}
+
+		e.Text = *resp.Text
+		if resp.Level != "" {
+			e.Level = resp.Level
+		}
+		if resp.Fields != nil {
+			e.Fields = resp.Fields
+		}
+
+	default:
+		if strings.Contains(e.Text, "\n") {
+			return false
+		}
+
+		out, ok := ext.roundTrip(e.Text)
+		if !ok || out == "" {
+			return false
+		}
+
+		e.Text = out
+	}
+
+	return true
+}
+
+// roundTrip sends a line to the process and waits for the line it
+// sends back. It reports false if the process couldn't be started,
+// exited, or didn't respond in time, counting the time taken to
+// write the line; an unresponsive process is killed, as a process
+// that skipped a response would have the rest taken for the wrong
+// requests. Other requests can be made while waiting.
+func (ext *external) roundTrip(line string) (string, bool) {
+	ext.mu.Lock()
+	if ext.proc != nil && ext.proc.exited {
+		ext.stop()
+		ext.mu.Unlock()
+		return "", false
+	}
+	if ext.proc == nil && !ext.start() {
+		ext.mu.Unlock()
+		return "", false
+	}
+
+	timer := time.NewTimer(ext.timeout)
+	defer timer.Stop()
+
+	proc := ext.proc
+	reply := make(chan string, 1)
+	proc.pending = append(proc.pending, reply)
+	proc.queue = append(proc.queue, line)
+	select {
+	case proc.wake <- struct{}{}:
+	default: // The writer has already been woken
+	}
+	ext.mu.Unlock()
+
+	select {
+	case out, ok := <-reply:
+		if !ok {
+			ext.stopIf(proc) // Exited, or stopped
+			return "", false
+		}
+		return out, true
+
+	case <-timer.C:
+		ext.mu.Lock()
+		defer ext.mu.Unlock()
+
+		if ext.proc == proc {
+			ext.logTimeout()
+			ext.stop()
+		}
+		return "", false
+	}
+}
+
+// stopIf stops proc if it is still the running process.
+func (ext *external) stopIf(proc *process) {
+	ext.mu.Lock()
+	defer ext.mu.Unlock()
+
+	if ext.proc == proc {
+		ext.stop()
+	}
+}
+
+// logTimeout logs a timeout, or counts it if one was logged less
+// than logInterval ago. It must be called with ext.mu held.
+func (ext *external) logTimeout() {
+	ext.timeouts++
+	if time.Since(ext.loggedAt) < logInterval {
+		return
+	}
+
+	if ext.timeouts == 1 {
+		log.Printf("%s: no response after %s, restarting", ext.command, ext.timeout)
+	} else {
+		log.Printf("%s: no response after %s, restarting (%d times)", ext.command, ext.timeout, ext.timeouts)
+	}
+	ext.timeouts = 0
+	ext.loggedAt = time.Now()
+}
+
+// start starts the process, unless it was started too recently. It
+// must be called with ext.mu held.
+func (ext *external) start() bool {
+	if time.Since(ext.started) < ext.restartDelay {
+		return false
+	}
+	ext.started = time.Now()
+
+	cmd := exec.Command("sh", "-c", ext.command)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		log.Println(ext.command+":", err)
+		return false
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		log.Println(ext.command+":", err)
+		return false
+	}
+
+	if err = cmd.Start(); err != nil {
+		log.Println(ext.command+":", err)
+		return false
+	}
+
+	proc := &process{
+		cmd:   cmd,
+		stdin: stdin,
+		wake:  make(chan struct{}, 1),
+		done:  make(chan struct{}),
+	}
+	ext.proc = proc
+
+	go ext.write(proc)
+	go ext.read(proc, stdout)
+
+	return true
+}
+
+// write writes the queued requests to the process, until it is
+// stopped. Stopping the process also ends a write that is blocked
+// on it.
+func (ext *external) write(proc *process) {
+	for {
+		select {
+		case <-proc.wake:
+		case <-proc.done:
+			return
+		}
+
+		ext.mu.Lock()
+		lines := proc.queue
+		proc.queue = nil
+		ext.mu.Unlock()
+
+		if len(lines) == 0 {
+			continue // Woken again for lines already written
+		}
+
+		if _, err := io.WriteString(proc.stdin, strings.Join(lines, "\n")+"\n"); err != nil {
+			ext.stopIf(proc)
+			return
+		}
+	}
+}
+
+// read hands each line the process writes to the oldest request
+// awaiting a response, until stdout closes. Lines nobody is waiting
+// for are discarded. Then it reaps the process.
+func (ext *external) read(proc *process, stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(nil, maxLine)
+	for scanner.Scan() {
+		ext.mu.Lock()
+		if len(proc.pending) > 0 {
+			proc.pending[0] <- scanner.Text()
+			proc.pending = proc.pending[1:]
+		}
+		ext.mu.Unlock()
+	}
+
+	ext.mu.Lock()
+	proc.exited = true
+	proc.fail()
+	ext.mu.Unlock()
+
+	var exitErr *exec.ExitError
+	if err := proc.cmd.Wait(); err != nil && !errors.As(err, &exitErr) {
+		log.Println(ext.command+":", err)
+	}
+}
+
+// stop kills the process, failing any requests awaiting responses.
+// It is reaped once its stdout closes. It must be called with
+// ext.mu held.
+func (ext *external) stop() {
+	proc := ext.proc
+	ext.proc = nil
+
+	proc.fail()
+	close(proc.done)
+	proc.stdin.Close()
+	_ = proc.cmd.Process.Kill()
+}
+
+// init makes the formatter available as type "external".
+func init() {
+	formatter.Register("external", formatter.Configured(New))
+}
+
+// New takes a config.ExternalConfig struct naming a command, and
+// returns an *external formatter that pipes entries through it.
+func New(conf config.ExternalConfig) *external {
+	ext := &external{
+		command:      conf.Command,
+		protocol:     conf.Protocol,
+		timeout:      conf.Timeout,
+		restartDelay: restartDelay,
+	}
+
+	if ext.command == "" {
+		log.Fatalln("external: no command")
+	}
+
+	switch ext.protocol {
+	case "":
+		ext.protocol = ProtocolLine
+	case ProtocolLine, ProtocolJSON:
+	default:
+		log.Fatalf("external protocol: unknown protocol %q", ext.protocol)
+	}
+
+	if ext.timeout <= 0 {
+		ext.timeout = defaultTimeout
+	}
+
+	return ext
+}
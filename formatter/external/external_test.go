@@ -0,0 +1,221 @@
+package external
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/assistcontrol/muxytail/config"
+	"github.com/assistcontrol/muxytail/formatter"
+)
+
+func TestFormatLine(t *testing.T) {
+	ext := New(config.ExternalConfig{
+		Command: `while read -r l; do case "$l" in x*) echo "X:$l";; *) echo;; esac; done`,
+	})
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		success  bool
+	}{
+		{
+			name:     "Formatted",
+			input:    "xyz",
+			expected: "X:xyz",
+			success:  true,
+		},
+		{
+			name:     "Declined",
+			input:    "abc",
+			expected: "abc",
+			success:  false,
+		},
+		{
+			name:     "Multi-line entries aren't sent",
+			input:    "x\nx",
+			expected: "x\nx",
+			success:  false,
+		},
+		{
+			name:     "Still running",
+			input:    "x2",
+			expected: "X:x2",
+			success:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, ok := ext.Format(tt.input)
+			if ok != tt.success {
+				t.Errorf("Format() success = %v, expected %v", ok, tt.success)
+			}
+			if result != tt.expected {
+				t.Errorf("Format() = %q, expected %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFormatJSON(t *testing.T) {
+	ext := New(config.ExternalConfig{
+		Command:  `while read -r l; do case "$l" in *decline*) echo '{}';; *) echo '{"text":"ok","level":"warn","fields":{"a":1}}';; esac; done`,
+		Protocol: ProtocolJSON,
+	})
+
+	e := formatter.NewEntry("app.log", "hello")
+	if !ext.FormatEntry(e) {
+		t.Fatal("FormatEntry() failed")
+	}
+	if e.Text != "ok" || e.Level != "warn" {
+		t.Errorf("FormatEntry() = %q at level %q, expected %q at level %q", e.Text, e.Level, "ok", "warn")
+	}
+	if fields := map[string]any{"a": 1.0}; !reflect.DeepEqual(e.Fields, fields) {
+		t.Errorf("Fields = %v, expected %v", e.Fields, fields)
+	}
+
+	e = formatter.NewEntry("app.log", "please decline")
+	if ext.FormatEntry(e) || e.Text != "please decline" {
+		t.Errorf("FormatEntry() of declined entry = %+v", e)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	ext := New(config.ExternalConfig{
+		Command: `while read -r l; do case "$l" in slow) sleep 5;; esac; echo "got $l"; done`,
+		Timeout: 50 * time.Millisecond,
+	})
+	ext.restartDelay = 0
+
+	if result, ok := ext.Format("slow"); ok {
+		t.Errorf("Format() = %q, expected timeout", result)
+	}
+
+	// The process is restarted, so the late response isn't mistaken
+	// for this one
+	if result, ok := ext.Format("fast"); !ok || result != "got fast" {
+		t.Errorf("Format() = %q, %v, expected %q", result, ok, "got fast")
+	}
+}
+
+func TestPipelined(t *testing.T) {
+	ext := New(config.ExternalConfig{
+		Command: `while read -r l; do echo "got $l"; done`,
+	})
+
+	// Concurrent requests each get their own response
+	var wg sync.WaitGroup
+	for _, s := range []string{"a", "b", "c", "d"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if result, ok := ext.Format(s); !ok || result != "got "+s {
+				t.Errorf("Format(%q) = %q, %v", s, result, ok)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestTimeoutConcurrent(t *testing.T) {
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+
+	ext := New(config.ExternalConfig{
+		Command: `sleep 5`,
+		Timeout: 200 * time.Millisecond,
+	})
+
+	// Requests waiting on a hung process time out together, rather
+	// than one after another
+	start := time.Now()
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok := ext.Format("x"); ok {
+				t.Error("Format() succeeded with a hung process")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("requests took %s, expected about one timeout", elapsed)
+	}
+	if n := strings.Count(logged.String(), "no response"); n != 1 {
+		t.Errorf("logged %d timeouts, expected 1:\n%s", n, logged.String())
+	}
+}
+
+func TestNotReading(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	ext := New(config.ExternalConfig{
+		Command: `sleep 30`,
+		Timeout: 200 * time.Millisecond,
+	})
+
+	// Far more than the pipe holds, so that writing blocks
+	line := strings.Repeat("x", 200)
+	done := make(chan bool)
+	for range 2000 {
+		go func() {
+			_, ok := ext.Format(line)
+			done <- ok
+		}()
+	}
+
+	deadline := time.After(5 * time.Second)
+	for range 2000 {
+		select {
+		case ok := <-done:
+			if ok {
+				t.Error("Format() succeeded with a process that doesn't read")
+			}
+		case <-deadline:
+			t.Fatal("requests hung writing to a process that doesn't read")
+		}
+	}
+}
+
+func TestRestart(t *testing.T) {
+	ext := New(config.ExternalConfig{
+		Command: `read -r l; echo "once $l"`,
+	})
+	ext.restartDelay = 0
+
+	for _, s := range []string{"a", "b"} {
+		// Each line is answered, then the process exits
+		if result, ok := ext.Format(s); !ok || result != "once "+s {
+			t.Errorf("Format(%q) = %q, %v", s, result, ok)
+		}
+		if _, ok := ext.Format("lost"); ok {
+			t.Error("Format() succeeded after the process exited")
+		}
+	}
+}
+
+func TestRestartDelay(t *testing.T) {
+	ext := New(config.ExternalConfig{Command: "exit 1"})
+
+	for range 2 {
+		if _, ok := ext.Format("x"); ok {
+			t.Error("Format() succeeded with a failing command")
+		}
+	}
+	if ext.proc != nil {
+		t.Error("process restarted within the restart delay")
+	}
+}
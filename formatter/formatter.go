@@ -1,5 +1,21 @@
 package formatter
 
+// Chain modes say how the formatters of a List are applied to a
+// line. An empty mode means ChainPipeline, so that colorize rules
+// apply to lines that a structured formatter has recognized too.
+const (
+	// ChainFirstMatch stops at the first formatter that succeeds.
+	ChainFirstMatch = "first-match"
+
+	// ChainAll runs every formatter in turn, each over the output
+	// of the last one that succeeded.
+	ChainAll = "all"
+
+	// ChainPipeline runs the structured formatters first-match, and
+	// then every Highlighter over their output.
+	ChainPipeline = "pipeline"
+)
+
 // Formatter defines the interface for a formatter that takes
 // a string and attempts to reformat/colorize it. Formatters
 // return the formatted string and a boolean indicating whether
@@ -8,5 +24,24 @@ type Formatter interface {
 	Format(string) (string, bool)
 }
 
+// Highlighter is implemented by formatters that colorize text where
+// it stands rather than parsing and re-rendering it (such as regex).
+// In a pipeline chain they run over the structured formatters'
+// output.
+type Highlighter interface {
+	Formatter
+	Highlighter()
+}
+
 // List defines a slice of Formatters.
 type List []Formatter
+
+// ValidChain reports whether mode is a known chain mode.
+func ValidChain(mode string) bool {
+	switch mode {
+	case "", ChainFirstMatch, ChainAll, ChainPipeline:
+		return true
+	}
+
+	return false
+}
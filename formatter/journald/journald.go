@@ -0,0 +1,164 @@
+// Format journald JSON entries (journalctl -o json) like syslog lines
+package journald
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/assistcontrol/muxytail/config"
+	"github.com/assistcontrol/muxytail/formatter"
+	"github.com/assistcontrol/muxytail/formatter/syslog"
+)
+
+// renderer is the syslog formatter's rendering half, which journald
+// entries are converted for.
+type renderer interface {
+	FillEntry(*formatter.Entry, *syslog.Message)
+}
+
+// struct journal formats journald entries using a syslog renderer
+type journal struct {
+	syslog renderer
+}
+
+// Format parses a journald JSON entry, converts it into a syslog
+// message, and renders it the same way as the syslog formatter.
+// It returns the formatted line and a boolean indicating whether
+// formatting was successful. If bool is false, the input was not
+// a journald JSON entry.
+func (j *journal) Format(in string) (string, bool) {
+	e := &formatter.Entry{Raw: in, Text: in}
+	ok := j.FormatEntry(e)
+
+	return e.Text, ok
+}
+
+// FormatEntry is Format for a formatter.Entry. The entry's fields
+// are the journal fields themselves (MESSAGE, _SYSTEMD_UNIT, ...).
+func (j *journal) FormatEntry(e *formatter.Entry) bool {
+	entry, err := parse(e.Text)
+	if err != nil {
+		return false
+	}
+
+	j.syslog.FillEntry(e, entry.Message())
+
+	e.Fields = make(map[string]any, len(entry))
+	for name, value := range entry {
+		e.Fields[name] = string(value)
+	}
+
+	return true
+}
+
+// init makes the formatter available as type "journald".
+func init() {
+	formatter.Register("journald", formatter.Configured(New))
+}
+
+// New takes a config.SyslogConfig struct specifying color strings,
+// and returns a *journal struct that is capable of parsing and
+// formatting journald JSON entries.
+func New(conf config.SyslogConfig) *journal {
+	return &journal{
+		syslog: syslog.New(conf),
+	}
+}
+
+//
+// JOURNALD ENTRY
+//
+
+// errNotJournal is returned when JSON isn't a journald entry.
+var errNotJournal = errors.New("not a journald entry")
+
+// journalEntry maps journal field names to their values.
+type journalEntry map[string]journalField
+
+// parse attempts to unmarshal a journald JSON entry. Only objects
+// carrying both MESSAGE and __REALTIME_TIMESTAMP are accepted, so
+// that other JSON logs are left for other formatters.
+func parse(in string) (journalEntry, error) {
+	var entry journalEntry
+	if err := json.Unmarshal([]byte(in), &entry); err != nil {
+		return nil, err
+	}
+
+	if _, ok := entry["MESSAGE"]; !ok {
+		return nil, errNotJournal
+	}
+	if _, ok := entry["__REALTIME_TIMESTAMP"]; !ok {
+		return nil, errNotJournal
+	}
+
+	return entry, nil
+}
+
+// journalEntry.Message converts the entry into a syslog.Message.
+func (e journalEntry) Message() *syslog.Message {
+	m := &syslog.Message{
+		Priority: -1,
+		Host:     e.get("_HOSTNAME"),
+		App:      e.get("_SYSTEMD_UNIT", "SYSLOG_IDENTIFIER", "_COMM"),
+		PID:      e.get("_PID", "SYSLOG_PID"),
+		Msg:      e.get("MESSAGE"),
+	}
+
+	if pri, err := strconv.Atoi(e.get("PRIORITY")); err == nil {
+		m.Priority = pri
+	}
+
+	if usec, err := strconv.ParseInt(e.get("__REALTIME_TIMESTAMP"), 10, 64); err == nil {
+		m.Time = time.UnixMicro(usec)
+		m.Timestamp = m.Time.Local().Format(time.Stamp)
+	}
+
+	return m
+}
+
+// journalEntry.get returns the value of the first of the named
+// fields that is present, or "" if none are.
+func (e journalEntry) get(names ...string) string {
+	for _, name := range names {
+		if f, ok := e[name]; ok {
+			return string(f)
+		}
+	}
+
+	return ""
+}
+
+// ---
+// journalField is a single journal field value. journalctl emits
+// most fields as strings, but fields that aren't valid UTF-8 are
+// emitted as arrays of byte values, and fields that occur more than
+// once in an entry are emitted as arrays of values.
+type journalField string
+
+// journalField.UnmarshalJSON accepts any of the encodings journalctl
+// uses. Repeated fields keep their first value.
+func (f *journalField) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*f = journalField(s)
+		return nil
+	}
+
+	var raw []byte
+	if err := json.Unmarshal(data, &raw); err == nil {
+		*f = journalField(raw)
+		return nil
+	}
+
+	var multi []journalField
+	if err := json.Unmarshal(data, &multi); err != nil {
+		return err
+	}
+	if len(multi) > 0 {
+		*f = multi[0]
+	}
+
+	return nil
+}
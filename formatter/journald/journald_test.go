@@ -0,0 +1,91 @@
+package journald
+
+import (
+	"bufio"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/assistcontrol/muxytail/config"
+	termcolor "github.com/gookit/color"
+)
+
+// ts renders a __REALTIME_TIMESTAMP the way Format does, so the
+// expectations don't depend on the local timezone.
+func ts(usec int64) string {
+	return time.UnixMicro(usec).Local().Format(time.Stamp)
+}
+
+func TestFormatFixtures(t *testing.T) {
+	f, err := os.Open("testdata/journal.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	width := 12
+	clr := New(config.SyslogConfig{ProgramWidth: &width})
+
+	expected := []string{
+		"[" + ts(1664590340263130) + "] myhost init.scope[1] Started Daily apt download activities.",
+		"[" + ts(1664590340267201) + "] myhost ssh.service[2211] error: kex_exchange_identification: Connection closed by remote host",
+		"[" + ts(1664590340271314) + "] myhost kernel       eth0: link down",
+		"[" + ts(1664590340275427) + "] myhost myapp[4242]  duplicate fields are fine",
+	}
+
+	scanner := bufio.NewScanner(f)
+	for i := 0; scanner.Scan(); i++ {
+		result, ok := clr.Format(scanner.Text())
+		if !ok {
+			t.Errorf("line %d: not recognized as a journal entry", i+1)
+			continue
+		}
+		if result != expected[i] {
+			t.Errorf("line %d: expected %q, got %q", i+1, expected[i], result)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	clr := New(config.SyslogConfig{
+		Severity: map[string]string{"err": "#FF0000"},
+	})
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		success  bool
+	}{
+		{
+			name:     "Priority color",
+			input:    `{"__REALTIME_TIMESTAMP":"0","PRIORITY":"3","MESSAGE":"boom"}`,
+			expected: "[" + ts(0) + "]  " + "                 " + termcolor.HEXStyle("#FF0000").Sprint("boom"),
+			success:  true,
+		},
+		{
+			name:     "Caddy JSON",
+			input:    `{"level":"info","ts":1664590340.2631304,"msg":"handled request","status":200}`,
+			expected: `{"level":"info","ts":1664590340.2631304,"msg":"handled request","status":200}`,
+			success:  false,
+		},
+		{
+			name:     "Not JSON",
+			input:    "Oct 11 22:14:15 myhost app: plain",
+			expected: "Oct 11 22:14:15 myhost app: plain",
+			success:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, success := clr.Format(tt.input)
+			if success != tt.success {
+				t.Errorf("expected success %v, got %v", tt.success, success)
+			}
+			if result != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package prettyjson
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+)
+
+// errNotJSON is returned for input that isn't a single JSON object
+// or array.
+var errNotJSON = errors.New("not a JSON object or array")
+
+// object is a decoded JSON object. Unlike a map, it keeps the keys
+// in the order they appeared in the line.
+type object []field
+
+// field is a single key/value pair of an object.
+type field struct {
+	Key   string
+	Value any
+}
+
+// array is a decoded JSON array.
+type array []any
+
+// object.Map converts the object into the form encoding/json would
+// have decoded it to: maps, []any, string, float64, bool and nil.
+func (obj object) Map() map[string]any {
+	m := make(map[string]any, len(obj))
+	for _, f := range obj {
+		m[f.Key] = plain(f.Value)
+	}
+
+	return m
+}
+
+// plain converts a decoded value for object.Map.
+func plain(v any) any {
+	switch v := v.(type) {
+	case object:
+		return v.Map()
+	case array:
+		s := make([]any, len(v))
+		for i := range v {
+			s[i] = plain(v[i])
+		}
+		return s
+	case json.Number:
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	}
+
+	return v
+}
+
+// decode parses a line holding a single JSON object or array. The
+// values within are object, array, string, json.Number, bool or
+// nil.
+func decode(in string) (any, error) {
+	trimmed := strings.TrimSpace(in)
+	if !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "[") {
+		return nil, errNotJSON
+	}
+
+	dec := json.NewDecoder(strings.NewReader(trimmed))
+	dec.UseNumber()
+
+	v, err := decodeValue(dec)
+	if err != nil {
+		return nil, err
+	}
+
+	// Trailing garbage means this wasn't a JSON line after all
+	if _, err = dec.Token(); err != io.EOF {
+		return nil, errNotJSON
+	}
+
+	return v, nil
+}
+
+// decodeValue reads the next complete value from dec.
+func decodeValue(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		obj := object{}
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+
+			value, err := decodeValue(dec)
+			if err != nil {
+				return nil, err
+			}
+
+			obj = append(obj, field{Key: keyTok.(string), Value: value})
+		}
+		_, err = dec.Token() // Closing }
+		return obj, err
+
+	case json.Delim('['):
+		arr := array{}
+		for dec.More() {
+			value, err := decodeValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, value)
+		}
+		_, err = dec.Token() // Closing ]
+		return arr, err
+	}
+
+	return tok, nil
+}
@@ -0,0 +1,248 @@
+// Pretty-print JSON lines that no other formatter recognized
+package prettyjson
+
+import (
+	"encoding/json"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/assistcontrol/muxytail/color"
+	"github.com/assistcontrol/muxytail/config"
+	"github.com/assistcontrol/muxytail/formatter"
+)
+
+// Display modes. Off leaves JSON lines to the following formatters.
+const (
+	ModeInline  = "inline"  // colorized, on one line
+	ModeExpand  = "expand"  // colorized, indented over many lines
+	ModeFlatten = "flatten" // colorized a.b.c=value pairs
+	ModeOff     = "off"
+)
+
+// modes is the order in which Cycle steps through the modes.
+var modes = []string{ModeInline, ModeExpand, ModeFlatten, ModeOff}
+
+// indent is one level of indentation in expand mode.
+const indent = "  "
+
+// struct printer holds the colorizer functions generated from the
+// passed JSON config, and the current display mode.
+type printer struct {
+	Key    color.Colorizer
+	String color.Colorizer
+	Number color.Colorizer
+	Bool   color.Colorizer
+	Null   color.Colorizer
+	Punct  color.Colorizer
+
+	mode atomic.Int32 // Index into modes; toggled from the keyboard
+}
+
+// Format parses a JSON object or array and renders it, colorized,
+// in the current mode. It returns the formatted line and a boolean
+// indicating whether formatting was successful. If bool is false,
+// the input was not JSON, or the mode is off.
+func (p *printer) Format(in string) (string, bool) {
+	e := &formatter.Entry{Raw: in, Text: in}
+	ok := p.FormatEntry(e)
+
+	return e.Text, ok
+}
+
+// FormatEntry is Format for a formatter.Entry. If the JSON is an
+// object, it becomes the entry's fields, and a string "level" field
+// becomes its level.
+func (p *printer) FormatEntry(e *formatter.Entry) bool {
+	mode := p.Mode()
+	if mode == ModeOff {
+		return false
+	}
+
+	v, err := decode(e.Text)
+	if err != nil {
+		return false
+	}
+
+	var b strings.Builder
+	switch mode {
+	case ModeExpand:
+		p.writeValue(&b, v, "\n", 0)
+	case ModeFlatten:
+		p.writeFlat(&b, "", v)
+	default:
+		p.writeValue(&b, v, "", 0)
+	}
+	e.Text = b.String()
+
+	if obj, ok := v.(object); ok {
+		e.Fields = obj.Map()
+		if level, ok := e.Fields["level"].(string); ok {
+			e.Level = strings.ToLower(level)
+		}
+	}
+
+	return true
+}
+
+// Mode returns the current display mode.
+func (p *printer) Mode() string {
+	return modes[p.mode.Load()]
+}
+
+// Cycle switches to the next display mode and returns its name.
+func (p *printer) Cycle() string {
+	for {
+		old := p.mode.Load()
+		next := (old + 1) % int32(len(modes))
+		if p.mode.CompareAndSwap(old, next) {
+			return modes[next]
+		}
+	}
+}
+
+// writeValue renders v as JSON. Compound values are broken over
+// lines, indented by depth, if newline is "\n"; otherwise they are
+// kept on one line.
+func (p *printer) writeValue(b *strings.Builder, v any, newline string, depth int) {
+	open, close, items := p.items(v)
+	if open == "" {
+		b.WriteString(p.scalar(v, true))
+		return
+	}
+
+	if len(items) == 0 {
+		b.WriteString(p.Punct(open + close))
+		return
+	}
+
+	// Items are separated by ", " on one line, or ",\n" + indent
+	sep, pad := ", ", ""
+	if newline != "" {
+		sep, pad = ",", newline+strings.Repeat(indent, depth+1)
+	}
+
+	b.WriteString(p.Punct(open))
+	for i, it := range items {
+		if i > 0 {
+			b.WriteString(p.Punct(sep))
+		}
+		b.WriteString(pad)
+
+		if it.key != nil {
+			b.WriteString(p.Key(strconv.Quote(*it.key)))
+			b.WriteString(p.Punct(":") + " ")
+		}
+		p.writeValue(b, it.value, newline, depth+1)
+	}
+	if newline != "" {
+		b.WriteString(newline + strings.Repeat(indent, depth))
+	}
+	b.WriteString(p.Punct(close))
+}
+
+// writeFlat renders v as space-separated key=value pairs, with the
+// keys of nested values joined by dots (a.b.0.c=value).
+func (p *printer) writeFlat(b *strings.Builder, prefix string, v any) {
+	open, close, items := p.items(v)
+	if len(items) == 0 {
+		value := p.Punct(open + close) // Empty object or array
+		if open == "" {
+			value = p.scalar(v, false)
+		}
+
+		if b.Len() > 0 {
+			b.WriteString(" ")
+		}
+		b.WriteString(p.Key(prefix) + p.Punct("=") + value)
+		return
+	}
+
+	for i, it := range items {
+		key := strconv.Itoa(i)
+		if it.key != nil {
+			key = *it.key
+		}
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		p.writeFlat(b, key, it.value)
+	}
+}
+
+// item is an element of an object (with a key) or array (without).
+type item struct {
+	key   *string
+	value any
+}
+
+// items returns the delimiters and elements of a compound value.
+// For scalars it returns empty delimiters and nil items.
+func (p *printer) items(v any) (string, string, []item) {
+	switch v := v.(type) {
+	case object:
+		items := make([]item, len(v))
+		for i := range v {
+			items[i] = item{key: &v[i].Key, value: v[i].Value}
+		}
+		return "{", "}", items
+	case array:
+		items := make([]item, len(v))
+		for i := range v {
+			items[i] = item{value: v[i]}
+		}
+		return "[", "]", items
+	}
+
+	return "", "", nil
+}
+
+// scalar renders a string, number, bool or null. Strings are quoted
+// if quote is set, or if they would otherwise be ambiguous.
+func (p *printer) scalar(v any, quote bool) string {
+	switch v := v.(type) {
+	case string:
+		if quote || v == "" || strings.ContainsAny(v, " \t\n\"") {
+			return p.String(strconv.Quote(v))
+		}
+		return p.String(v)
+	case json.Number:
+		return p.Number(v.String())
+	case bool:
+		return p.Bool(strconv.FormatBool(v))
+	}
+
+	return p.Null("null")
+}
+
+// init makes the formatter available as type "json".
+func init() {
+	formatter.Register("json", formatter.Configured(New))
+}
+
+// New takes a config.JSONConfig struct specifying color strings and
+// the initial mode, and returns a *printer struct that is capable
+// of pretty-printing JSON lines.
+func New(conf config.JSONConfig) *printer {
+	p := &printer{
+		Key:    color.GenerateColorizer(conf.Key),
+		String: color.GenerateColorizer(conf.String),
+		Number: color.GenerateColorizer(conf.Number),
+		Bool:   color.GenerateColorizer(conf.Bool),
+		Null:   color.GenerateColorizer(conf.Null),
+		Punct:  color.GenerateColorizer(conf.Punct),
+	}
+
+	if conf.Mode != "" {
+		i := slices.Index(modes, conf.Mode)
+		if i < 0 {
+			log.Fatalf("json mode: unknown mode %q", conf.Mode)
+		}
+		p.mode.Store(int32(i))
+	}
+
+	return p
+}
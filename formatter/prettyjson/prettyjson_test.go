@@ -0,0 +1,166 @@
+package prettyjson
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/assistcontrol/muxytail/config"
+	"github.com/assistcontrol/muxytail/formatter"
+	termcolor "github.com/gookit/color"
+)
+
+func TestFormat(t *testing.T) {
+	const line = `{"msg":"hello world","n":1.5,"ok":true,"none":null,"tags":["a","b"],"req":{"host":"example.com","empty":{}}}`
+
+	tests := []struct {
+		name     string
+		mode     string
+		input    string
+		expected string
+		success  bool
+	}{
+		{
+			name:     "Inline",
+			mode:     ModeInline,
+			input:    line,
+			expected: `{"msg": "hello world", "n": 1.5, "ok": true, "none": null, "tags": ["a", "b"], "req": {"host": "example.com", "empty": {}}}`,
+			success:  true,
+		},
+		{
+			name:  "Expand",
+			mode:  ModeExpand,
+			input: line,
+			expected: `{
+  "msg": "hello world",
+  "n": 1.5,
+  "ok": true,
+  "none": null,
+  "tags": [
+    "a",
+    "b"
+  ],
+  "req": {
+    "host": "example.com",
+    "empty": {}
+  }
+}`,
+			success: true,
+		},
+		{
+			name:     "Flatten",
+			mode:     ModeFlatten,
+			input:    line,
+			expected: `msg="hello world" n=1.5 ok=true none=null tags.0=a tags.1=b req.host=example.com req.empty={}`,
+			success:  true,
+		},
+		{
+			name:     "Top-level array",
+			mode:     ModeFlatten,
+			input:    `[{"a":1},2]`,
+			expected: `0.a=1 1=2`,
+			success:  true,
+		},
+		{
+			name:     "Off",
+			mode:     ModeOff,
+			input:    line,
+			expected: line,
+			success:  false,
+		},
+		{
+			name:     "Scalar",
+			mode:     ModeInline,
+			input:    `42`,
+			expected: `42`,
+			success:  false,
+		},
+		{
+			name:     "Trailing garbage",
+			mode:     ModeInline,
+			input:    `{"a":1} and more`,
+			expected: `{"a":1} and more`,
+			success:  false,
+		},
+		{
+			name:     "Truncated",
+			mode:     ModeInline,
+			input:    `{"a":`,
+			expected: `{"a":`,
+			success:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, success := New(config.JSONConfig{Mode: tt.mode}).Format(tt.input)
+			if success != tt.success {
+				t.Errorf("expected success %v, got %v", tt.success, success)
+			}
+			if result != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestFormatColors(t *testing.T) {
+	p := New(config.JSONConfig{
+		Key:    "#0000FF",
+		String: "#00FF00",
+		Number: "#FF00FF",
+		Bool:   "#FFFF00",
+	})
+	key := termcolor.HEXStyle("#0000FF").Sprint
+	str := termcolor.HEXStyle("#00FF00").Sprint
+	num := termcolor.HEXStyle("#FF00FF").Sprint
+	boolean := termcolor.HEXStyle("#FFFF00").Sprint
+
+	expected := "{" + key(`"a"`) + ": " + str(`"x"`) + ", " +
+		key(`"b"`) + ": " + num("2") + ", " +
+		key(`"c"`) + ": " + boolean("false") + "}"
+
+	if result, _ := p.Format(`{"a":"x","b":2,"c":false}`); result != expected {
+		t.Errorf("expected %q, got %q", expected, result)
+	}
+}
+
+func TestCycle(t *testing.T) {
+	p := New(config.JSONConfig{})
+
+	for _, want := range []string{ModeExpand, ModeFlatten, ModeOff, ModeInline} {
+		if got := p.Cycle(); got != want {
+			t.Errorf("Cycle() = %q, want %q", got, want)
+		}
+		if got := p.Mode(); got != want {
+			t.Errorf("Mode() = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestFormatEntry(t *testing.T) {
+	p := New(config.JSONConfig{})
+
+	e := formatter.NewEntry("app.log", `{"level":"WARN","msg":"low disk","free":{"gb":1.5}}`)
+	if !p.FormatEntry(e) {
+		t.Fatal("FormatEntry() failed")
+	}
+
+	if e.Level != "warn" {
+		t.Errorf("Level = %q, want %q", e.Level, "warn")
+	}
+
+	fields := map[string]any{
+		"level": "WARN",
+		"msg":   "low disk",
+		"free":  map[string]any{"gb": 1.5},
+	}
+	if !reflect.DeepEqual(e.Fields, fields) {
+		t.Errorf("Fields = %v, want %v", e.Fields, fields)
+	}
+
+	// Arrays are formatted, but have no fields
+	e = formatter.NewEntry("app.log", `[1, 2]`)
+	if !p.FormatEntry(e) || e.Fields != nil {
+		t.Errorf("FormatEntry() array entry: %+v", e)
+	}
+}
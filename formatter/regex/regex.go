@@ -1,11 +1,12 @@
 package regex
 
 import (
-	"fmt"
 	"regexp"
+	"strings"
 
 	"github.com/assistcontrol/muxytail/color"
 	"github.com/assistcontrol/muxytail/config"
+	"github.com/assistcontrol/muxytail/formatter"
 )
 
 // colorList is a slice of known reColor structs. It is created
@@ -21,11 +22,9 @@ type reColor struct {
 }
 
 // addREs turns a slice of strings into regexps and appends
-// them to the RE field. The string arguments are converted to
-// regexps by surrounding them in parentheses.
+// them to the RE field.
 func (rc *reColor) addREs(REs []string) {
 	for _, re := range REs {
-		re = fmt.Sprintf("(%s)", re)
 		rc.RE = append(rc.RE, regexp.MustCompile(re))
 	}
 }
@@ -37,13 +36,84 @@ func (colors colorList) Format(in string) (string, bool) {
 
 	for _, clr := range colors {
 		for _, re := range clr.RE {
-			out = re.ReplaceAllString(out, clr.Colorizer("$1"))
+			out = colorizePlain(re, out, clr.Colorizer)
 		}
 	}
 
 	return out, in != out
 }
 
+// Highlighter marks the regex formatter as a formatter.Highlighter,
+// which colorizes text in place and so can run over the output of
+// other formatters.
+func (colors colorList) Highlighter() {}
+
+// colorizePlain runs colorizeMatches over the text between color
+// escape sequences, so that already colorized input (from earlier
+// regexps, or other formatters in a pipeline) isn't corrupted.
+func colorizePlain(re *regexp.Regexp, s string, clr color.Colorizer) string {
+	escapes := color.Escapes(s) // Not to be matched into
+	if escapes == nil {
+		return colorizeMatches(re, s, clr)
+	}
+
+	var b strings.Builder
+	last := 0
+
+	for _, esc := range escapes {
+		b.WriteString(colorizeMatches(re, s[last:esc[0]], clr))
+		b.WriteString(s[esc[0]:esc[1]])
+		last = esc[1]
+	}
+
+	b.WriteString(colorizeMatches(re, s[last:], clr))
+
+	return b.String()
+}
+
+// colorizeMatches colorizes every match of re in s. Each match is
+// colorized separately, so that colorizers that depend on the text
+// (such as hash coloring) see the matched value. If re has named
+// groups, only the text of those groups is colorized, which lets
+// a rule pick a value out of its surrounding context, e.g.
+// `user=(?P<user>\w+)`.
+func colorizeMatches(re *regexp.Regexp, s string, clr color.Colorizer) string {
+	var groups []int
+	for i, name := range re.SubexpNames() {
+		if name != "" {
+			groups = append(groups, i)
+		}
+	}
+	if groups == nil {
+		groups = []int{0}
+	}
+
+	var b strings.Builder
+	last := 0
+
+	for _, match := range re.FindAllStringSubmatchIndex(s, -1) {
+		for _, g := range groups {
+			start, end := match[2*g], match[2*g+1]
+			if start < last || start == end {
+				continue // Unmatched, empty, or nested in a previous group
+			}
+
+			b.WriteString(s[last:start])
+			b.WriteString(clr(s[start:end]))
+			last = end
+		}
+	}
+
+	b.WriteString(s[last:])
+
+	return b.String()
+}
+
+// init makes the formatter available as type "regex".
+func init() {
+	formatter.Register("regex", formatter.Configured(New))
+}
+
 // New creates a new regex formatter. It takes a config.REConfig
 // and returns a formatter that colorizes matches of each regexp.
 func New(conf config.REConfig) colorList {
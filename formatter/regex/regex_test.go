@@ -0,0 +1,91 @@
+package regex
+
+import (
+	"testing"
+
+	"github.com/assistcontrol/muxytail/color"
+	"github.com/assistcontrol/muxytail/config"
+	termcolor "github.com/gookit/color"
+)
+
+func TestFormat(t *testing.T) {
+	red := termcolor.HEXStyle("#FF0000").Sprint
+	hash := color.GenerateColorizer(color.Hash)
+
+	tests := []struct {
+		name     string
+		conf     config.REConfig
+		input    string
+		expected string
+		success  bool
+	}{
+		{
+			name:     "Whole match",
+			conf:     config.REConfig{"#FF0000": {"ERROR"}},
+			input:    "ERROR: disk ERROR",
+			expected: red("ERROR") + ": disk " + red("ERROR"),
+			success:  true,
+		},
+		{
+			name:     "Alternation",
+			conf:     config.REConfig{"#FF0000": {"ERROR|FATAL"}},
+			input:    "FATAL: boom",
+			expected: red("FATAL") + ": boom",
+			success:  true,
+		},
+		{
+			name:     "Named group",
+			conf:     config.REConfig{"#FF0000": {`user=(?P<user>\w+)`}},
+			input:    "login user=alice ok",
+			expected: "login user=" + red("alice") + " ok",
+			success:  true,
+		},
+		{
+			name:     "Hash colors each match by value",
+			conf:     config.REConfig{color.Hash: {`\d+\.\d+\.\d+\.\d+`}},
+			input:    "192.0.2.1 -> 192.0.2.2 -> 192.0.2.1",
+			expected: hash("192.0.2.1") + " -> " + hash("192.0.2.2") + " -> " + hash("192.0.2.1"),
+			success:  true,
+		},
+		{
+			name:     "Hash named groups",
+			conf:     config.REConfig{color.Hash: {`(?P<src>\S+) -> (?P<dst>\S+)`}},
+			input:    "a -> b",
+			expected: hash("a") + " -> " + hash("b"),
+			success:  true,
+		},
+		{
+			name:     "No match",
+			conf:     config.REConfig{"#FF0000": {"ERROR"}},
+			input:    "all good",
+			expected: "all good",
+			success:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, success := New(tt.conf).Format(tt.input)
+			if success != tt.success {
+				t.Errorf("expected success %v, got %v", tt.success, success)
+			}
+			if result != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestFormatSkipsEscapes(t *testing.T) {
+	red := termcolor.HEXStyle("#FF0000").Sprint
+	blue := termcolor.HEXStyle("#0000FF").Sprint
+
+	// The digits of the escape codes around "GET" must not match
+	input := blue("GET") + " /index 200"
+	expected := blue("GET") + " /index " + red("200")
+
+	result, _ := New(config.REConfig{"#FF0000": {`\d+`}}).Format(input)
+	if result != expected {
+		t.Errorf("expected %q, got %q", expected, result)
+	}
+}
@@ -0,0 +1,81 @@
+package formatter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Decoder decodes a formatter's configuration into v, which is a
+// pointer to the formatter's own config struct.
+type Decoder func(v any) error
+
+// Constructor creates a formatter from its configuration.
+type Constructor func(decode Decoder) (Formatter, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Constructor)
+)
+
+// Register makes a formatter available under name, for selection
+// by the type of a formatters entry in the config. Formatter
+// packages call it from init(), so importing a package is enough to
+// make its formatter available. Register panics if name is already
+// registered.
+func Register(name string, c Constructor) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if c == nil {
+		panic("formatter: Register constructor is nil")
+	}
+	if _, dup := registry[name]; dup {
+		panic("formatter: Register called twice for " + name)
+	}
+
+	registry[name] = c
+}
+
+// New creates the formatter registered under name, decoding its
+// configuration with decode.
+func New(name string, decode Decoder) (Formatter, error) {
+	registryMu.RLock()
+	c, ok := registry[name]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown formatter type %q (known: %s)",
+			name, strings.Join(Registered(), ", "))
+	}
+
+	return c(decode)
+}
+
+// Registered returns the sorted names of the registered formatters.
+func Registered() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Configured adapts a formatter package's New function, which takes
+// the package's config struct, into a Constructor.
+func Configured[C any, F Formatter](newFormatter func(C) F) Constructor {
+	return func(decode Decoder) (Formatter, error) {
+		var conf C
+		if err := decode(&conf); err != nil {
+			return nil, err
+		}
+
+		return newFormatter(conf), nil
+	}
+}
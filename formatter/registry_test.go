@@ -0,0 +1,60 @@
+package formatter
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// prefixConfig is the config of the prefixer test formatter.
+type prefixConfig struct {
+	Prefix string
+}
+
+// prefixer prefixes every line with its configured prefix.
+type prefixer string
+
+func (p prefixer) Format(in string) (string, bool) {
+	return string(p) + in, true
+}
+
+func newPrefixer(conf prefixConfig) prefixer {
+	return prefixer(conf.Prefix)
+}
+
+func TestRegistry(t *testing.T) {
+	Register("test-prefix", Configured(newPrefixer))
+
+	if !slices.Contains(Registered(), "test-prefix") {
+		t.Errorf("Registered() = %v, missing test-prefix", Registered())
+	}
+
+	f, err := New("test-prefix", func(v any) error {
+		v.(*prefixConfig).Prefix = "> "
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if out, _ := f.Format("x"); out != "> x" {
+		t.Errorf("Format() = %q, want %q", out, "> x")
+	}
+
+	// Decoding errors are passed back
+	decodeErr := errors.New("bad config")
+	if _, err = New("test-prefix", func(any) error { return decodeErr }); !errors.Is(err, decodeErr) {
+		t.Errorf("New() error = %v, want %v", err, decodeErr)
+	}
+
+	if _, err = New("no-such-formatter", nil); err == nil || !strings.Contains(err.Error(), "test-prefix") {
+		t.Errorf("New() of unknown type error = %v, want list of known types", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("duplicate Register() did not panic")
+		}
+	}()
+	Register("test-prefix", Configured(newPrefixer))
+}
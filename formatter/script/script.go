@@ -0,0 +1,233 @@
+// Format log entries with a Starlark script
+package script
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/assistcontrol/muxytail/color"
+	"github.com/assistcontrol/muxytail/config"
+	"github.com/assistcontrol/muxytail/formatter"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// defaultMaxSteps is the default limit on the work a script may do
+// per line, so that a runaway loop can't hang muxytail.
+const defaultMaxSteps = 1_000_000
+
+// entryPoint is the function the script must define. It is called
+// as format(line, fields), where line is the line as read and
+// fields is a dict of the entry's parsed fields, or None. It returns
+// the rendered line, None to leave the line to the next formatter,
+// or drop to discard it.
+const entryPoint = "format"
+
+// dropSignal is the type of the predeclared drop value.
+type dropSignal struct{}
+
+func (dropSignal) String() string        { return "drop" }
+func (dropSignal) Type() string          { return "drop" }
+func (dropSignal) Freeze()               {}
+func (dropSignal) Truth() starlark.Bool  { return starlark.True }
+func (dropSignal) Hash() (uint32, error) { return 0, nil }
+
+// struct script is a loaded script. Its globals are frozen, so that
+// lines can be formatted concurrently, each in its own thread.
+type script struct {
+	name     string
+	format   starlark.Callable
+	maxSteps uint64
+
+	colorMu    sync.Mutex
+	colorizers map[string]color.Colorizer
+
+	errLogged atomic.Bool
+}
+
+// Format runs the script over a line. It returns the script's
+// output, and a boolean indicating whether the script formatted the
+// line. A dropped line is rendered as "".
+func (s *script) Format(in string) (string, bool) {
+	e := formatter.NewEntry("", in)
+	ok := s.FormatEntry(e)
+
+	return e.Text, ok
+}
+
+// FormatEntry is Format for a formatter.Entry. The script sees the
+// line as read, whatever earlier formatters made of it, and the
+// fields they filled in. As a formatter that parses a line usually
+// ends the chain, a JSON line's fields are parsed here if no earlier
+// formatter did. The script may drop the entry.
+func (s *script) FormatEntry(e *formatter.Entry) bool {
+	var fields starlark.Value = starlark.None
+	if e.Fields != nil {
+		fields = toStarlark(e.Fields)
+	} else if parsed := parseFields(e.Raw); parsed != nil {
+		fields = toStarlark(parsed)
+	}
+
+	thread := &starlark.Thread{
+		Name:  s.name,
+		Print: func(*starlark.Thread, string) {}, // No writing over the display
+	}
+	thread.SetMaxExecutionSteps(s.maxSteps)
+
+	v, err := starlark.Call(thread, s.format, starlark.Tuple{starlark.String(e.Raw), fields}, nil)
+	if err != nil {
+		// Logged once, rather than for every line
+		if !s.errLogged.Swap(true) {
+			log.Println(s.name+":", err)
+		}
+		return false
+	}
+
+	switch v := v.(type) {
+	case starlark.NoneType:
+		return false
+	case dropSignal:
+		e.Text = ""
+		e.Drop = true
+	case starlark.String:
+		e.Text = string(v)
+	default:
+		if !s.errLogged.Swap(true) {
+			log.Printf("%s: %s returned %s, not a string", s.name, entryPoint, v.Type())
+		}
+		return false
+	}
+
+	return true
+}
+
+// colorize is the script's color(spec, text) builtin. It colorizes
+// text with a color string, as used throughout the config.
+func (s *script) colorize(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var spec, text string
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 2, &spec, &text); err != nil {
+		return nil, err
+	}
+
+	s.colorMu.Lock()
+	c, ok := s.colorizers[spec]
+	if !ok {
+		c = color.GenerateColorizer(spec)
+		s.colorizers[spec] = c
+	}
+	s.colorMu.Unlock()
+
+	return starlark.String(c(text)), nil
+}
+
+// parseFields returns the fields of a JSON object line, or nil if
+// the line isn't one.
+func parseFields(line string) map[string]any {
+	if !strings.HasPrefix(strings.TrimSpace(line), "{") {
+		return nil
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(line), &fields); err != nil {
+		return nil
+	}
+
+	return fields
+}
+
+// toStarlark converts a parsed field value to a Starlark value.
+func toStarlark(v any) starlark.Value {
+	switch v := v.(type) {
+	case nil:
+		return starlark.None
+	case bool:
+		return starlark.Bool(v)
+	case string:
+		return starlark.String(v)
+	case int:
+		return starlark.MakeInt(v)
+	case float64:
+		if v == float64(int64(v)) {
+			return starlark.MakeInt64(int64(v))
+		}
+		return starlark.Float(v)
+	case []any:
+		list := make([]starlark.Value, len(v))
+		for i := range v {
+			list[i] = toStarlark(v[i])
+		}
+		return starlark.NewList(list)
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		dict := starlark.NewDict(len(v))
+		for _, k := range keys {
+			_ = dict.SetKey(starlark.String(k), toStarlark(v[k]))
+		}
+		return dict
+	}
+
+	return starlark.String(fmt.Sprint(v))
+}
+
+// init makes the formatter available as type "script".
+func init() {
+	formatter.Register("script", formatter.Configured(New))
+}
+
+// New takes a config.ScriptConfig struct giving a script, and
+// returns a *script formatter that runs it over each line. Scripts
+// are sandboxed: they can't load modules or do any I/O.
+func New(conf config.ScriptConfig) *script {
+	s := &script{
+		name:       "script",
+		maxSteps:   conf.MaxSteps,
+		colorizers: make(map[string]color.Colorizer),
+	}
+	if s.maxSteps == 0 {
+		s.maxSteps = defaultMaxSteps
+	}
+
+	if conf.File != "" && conf.Script != "" {
+		log.Fatalln("script: both file and script are set")
+	}
+
+	var src any = conf.Script
+	if conf.File != "" {
+		data, err := os.ReadFile(conf.File)
+		if err != nil {
+			log.Fatalln("script:", err)
+		}
+		s.name, src = conf.File, data
+	}
+
+	predeclared := starlark.StringDict{
+		"color": starlark.NewBuiltin("color", s.colorize),
+		"drop":  dropSignal{},
+	}
+
+	thread := &starlark.Thread{Name: s.name, Print: func(*starlark.Thread, string) {}}
+	globals, err := starlark.ExecFileOptions(&syntax.FileOptions{}, thread, s.name, src, predeclared)
+	if err != nil {
+		log.Fatalln("script:", err)
+	}
+	globals.Freeze()
+
+	format, ok := globals[entryPoint].(starlark.Callable)
+	if !ok {
+		log.Fatalf("script %s: no %s function", s.name, entryPoint)
+	}
+	s.format = format
+
+	return s
+}
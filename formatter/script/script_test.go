@@ -0,0 +1,130 @@
+package script
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/assistcontrol/muxytail/config"
+	"github.com/assistcontrol/muxytail/formatter"
+	termcolor "github.com/gookit/color"
+)
+
+const testScript = `
+def format(line, fields):
+    if fields != None:
+        return "%s: %s" % (fields["level"].upper(), fields["msg"])
+    if line.startswith("debug"):
+        return drop
+    if line.startswith("err"):
+        return color("#FF0000", line)
+    if line.startswith("loop"):
+        for i in range(100000000):
+            pass
+    return None
+`
+
+func TestFormatEntry(t *testing.T) {
+	s := New(config.ScriptConfig{Script: testScript, MaxSteps: 10000})
+	red := termcolor.HEXStyle("#FF0000").Sprint
+
+	tests := []struct {
+		name     string
+		input    string
+		text     string // as rendered by earlier formatters, if any
+		fields   map[string]any
+		expected string
+		success  bool
+		drop     bool
+	}{
+		{
+			name:     "Colorized",
+			input:    "error: disk full",
+			expected: red("error: disk full"),
+			success:  true,
+		},
+		{
+			name:     "Fields",
+			input:    `{"level":"warn","msg":"low disk"}`,
+			fields:   map[string]any{"level": "warn", "msg": "low disk"},
+			expected: "WARN: low disk",
+			success:  true,
+		},
+		{
+			name:     "Fields parsed from a JSON line",
+			input:    `{"level":"error","msg":"disk full"}`,
+			expected: "ERROR: disk full",
+			success:  true,
+		},
+		{
+			name:     "Line as read",
+			input:    "error: disk full",
+			text:     "[12:00] error: disk full",
+			expected: red("error: disk full"),
+			success:  true,
+		},
+		{
+			name:     "Dropped",
+			input:    "debug: noise",
+			expected: "",
+			success:  true,
+			drop:     true,
+		},
+		{
+			name:     "Declined",
+			input:    "info: ok",
+			expected: "info: ok",
+			success:  false,
+		},
+		{
+			name:     "Step limit",
+			input:    "loop",
+			expected: "loop",
+			success:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := formatter.NewEntry("test", tt.input)
+			e.Fields = tt.fields
+			if tt.text != "" {
+				e.Text = tt.text
+			}
+
+			if ok := s.FormatEntry(e); ok != tt.success {
+				t.Errorf("FormatEntry() success = %v, expected %v", ok, tt.success)
+			}
+			if e.Text != tt.expected {
+				t.Errorf("FormatEntry() = %q, expected %q", e.Text, tt.expected)
+			}
+			if e.Drop != tt.drop {
+				t.Errorf("FormatEntry() drop = %v, expected %v", e.Drop, tt.drop)
+			}
+		})
+	}
+}
+
+func TestScriptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "upper.star")
+	src := "def format(line, fields):\n    return line.upper()\n"
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := New(config.ScriptConfig{File: path})
+	if result, ok := s.Format("abc"); !ok || result != "ABC" {
+		t.Errorf("Format() = %q, %v, expected %q", result, ok, "ABC")
+	}
+}
+
+func Test_toStarlark(t *testing.T) {
+	v := toStarlark(map[string]any{
+		"b": []any{1.0, 1.5, true, nil},
+		"a": map[string]any{"x": "y"},
+	})
+
+	if expected := `{"a": {"x": "y"}, "b": [1, 1.5, True, None]}`; v.String() != expected {
+		t.Errorf("toStarlark() = %s, expected %s", v, expected)
+	}
+}
@@ -0,0 +1,298 @@
+package syslog
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//
+// SYSLOG MESSAGE DATA STRUCTURE
+//
+
+// struct Message holds a single syslog message, either parsed from
+// a line or built by another formatter (e.g. journald). Fields that
+// are absent from the line (or NILVALUE in RFC 5424) are empty.
+type Message struct {
+	Priority  int       // -1 if the line carried no <PRI>
+	Timestamp string    // timestamp as it appeared in the line
+	Time      time.Time // parsed timestamp; zero if unparseable
+	Host      string
+	App       string
+	PID       string
+	MsgID     string
+	SD        []sdElement
+	Msg       string
+}
+
+// struct sdElement is a single RFC 5424 STRUCTURED-DATA element,
+// e.g. [exampleSDID@32473 iut="3" eventSource="Application"].
+type sdElement struct {
+	ID     string
+	Params []sdParam
+}
+
+// struct sdParam is a single name="value" pair inside an sdElement.
+type sdParam struct {
+	Name, Value string
+}
+
+// Severity returns the syslog severity (0-7) of the message, or -1
+// if the line carried no priority.
+func (m *Message) Severity() int {
+	if m.Priority < 0 {
+		return -1
+	}
+
+	return m.Priority % 8
+}
+
+// entryLevels are the formatter.Entry levels for each severity,
+// using the same names as other formatters where there is one.
+var entryLevels = [...]string{
+	"emerg", "alert", "crit", "error", "warn", "notice", "info", "debug",
+}
+
+// Level returns the formatter.Entry level of the message, or "" if
+// the line carried no priority.
+func (m *Message) Level() string {
+	sev := m.Severity()
+	if sev < 0 {
+		return ""
+	}
+
+	return entryLevels[sev]
+}
+
+// Fields returns the message's fields for a formatter.Entry. Empty
+// fields are left out. Structured data elements become nested
+// maps, keyed by SD-ID and then parameter name.
+func (m *Message) Fields() map[string]any {
+	fields := make(map[string]any)
+
+	add := func(key, value string) {
+		if value != "" {
+			fields[key] = value
+		}
+	}
+	add("host", m.Host)
+	add("app", m.App)
+	add("pid", m.PID)
+	add("msgid", m.MsgID)
+	add("msg", m.Msg)
+
+	if m.Priority >= 0 {
+		fields["facility"] = m.Priority / 8
+		fields["severity"] = m.Severity()
+	}
+
+	for _, el := range m.SD {
+		params := make(map[string]any, len(el.Params))
+		for _, p := range el.Params {
+			params[p.Name] = p.Value
+		}
+		fields[el.ID] = params
+	}
+
+	return fields
+}
+
+//
+// PARSING
+//
+
+// errNotSyslog is returned when a line isn't recognized as syslog.
+var errNotSyslog = errors.New("not a syslog line")
+
+// priRE matches the optional <PRI> prefix.
+var priRE = regexp.MustCompile(`^<(\d{1,3})>`)
+
+// rfc5424RE matches the header of an RFC 5424 line following the
+// <PRI>: VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID, and
+// captures the remainder (STRUCTURED-DATA and MSG).
+var rfc5424RE = regexp.MustCompile(`^1 (\S+) (\S+) (\S+) (\S+) (\S+) (.*)$`)
+
+// levelRE matches a word in capitals, such as INFO. After an RFC
+// 3339 timestamp it is far more likely the level of an ordinary
+// application log line than a host name.
+var levelRE = regexp.MustCompile(`^[A-Z]+$`)
+
+// rfc3164RE matches a BSD-style line as written by syslogd and
+// rsyslog: TIMESTAMP HOSTNAME TAG[PID]: MSG. The timestamp is
+// either the classic "Jan _2 15:04:05" or RFC 3339.
+var rfc3164RE = regexp.MustCompile(`^([A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2}|\d{4}-\d{2}-\d{2}T\S+) (\S+) ([^\s\[:]+)(?:\[([^\]]*)\])?: ?(.*)$`)
+
+// parse attempts to parse a syslog line in either RFC 5424 or
+// RFC 3164 format. It returns errNotSyslog if neither matches.
+func parse(in string) (*Message, error) {
+	m := &Message{Priority: -1}
+
+	if pri := priRE.FindStringSubmatch(in); pri != nil {
+		p, _ := strconv.Atoi(pri[1])
+		if p > 191 {
+			return nil, errNotSyslog
+		}
+
+		m.Priority = p
+		in = in[len(pri[0]):]
+
+		if parts := rfc5424RE.FindStringSubmatch(in); parts != nil {
+			return parse5424(m, parts)
+		}
+	}
+
+	parts := rfc3164RE.FindStringSubmatch(in)
+	if parts == nil {
+		return nil, errNotSyslog
+	}
+	rfc3339 := parts[1][0] >= '0' && parts[1][0] <= '9'
+	if rfc3339 && m.Priority < 0 && levelRE.MatchString(parts[2]) {
+		return nil, errNotSyslog
+	}
+
+	m.Timestamp = parts[1]
+	m.Time = parseTime(parts[1], time.Now())
+	m.Host = parts[2]
+	m.App = parts[3]
+	m.PID = parts[4]
+	m.Msg = parts[5]
+
+	return m, nil
+}
+
+// parse5424 fills m from the submatches of rfc5424RE.
+func parse5424(m *Message, parts []string) (*Message, error) {
+	m.Timestamp = nilValue(parts[1])
+	m.Time = parseTime(m.Timestamp, time.Now())
+	m.Host = nilValue(parts[2])
+	m.App = nilValue(parts[3])
+	m.PID = nilValue(parts[4])
+	m.MsgID = nilValue(parts[5])
+
+	sd, msg, err := parseSD(parts[6])
+	if err != nil {
+		return nil, err
+	}
+
+	m.SD = sd
+	m.Msg = strings.TrimPrefix(msg, "\ufeff") // Drop the UTF-8 BOM
+
+	return m, nil
+}
+
+// parseSD parses the STRUCTURED-DATA at the start of s, returning
+// the elements and the remaining message text.
+func parseSD(s string) ([]sdElement, string, error) {
+	if strings.HasPrefix(s, "-") {
+		return nil, strings.TrimPrefix(s[1:], " "), nil
+	}
+
+	var elements []sdElement
+	for strings.HasPrefix(s, "[") {
+		var (
+			el  sdElement
+			err error
+		)
+
+		el, s, err = parseSDElement(s[1:])
+		if err != nil {
+			return nil, "", err
+		}
+
+		elements = append(elements, el)
+	}
+
+	if elements == nil {
+		return nil, "", errNotSyslog
+	}
+
+	return elements, strings.TrimPrefix(s, " "), nil
+}
+
+// parseSDElement parses a single element, starting just after its
+// opening bracket. It returns the element and the text following
+// its closing bracket.
+func parseSDElement(s string) (sdElement, string, error) {
+	var el sdElement
+
+	end := strings.IndexAny(s, " ]")
+	if end <= 0 {
+		return el, "", errNotSyslog
+	}
+	el.ID, s = s[:end], s[end:]
+
+	for {
+		s = strings.TrimPrefix(s, " ")
+		if strings.HasPrefix(s, "]") {
+			return el, s[1:], nil
+		}
+
+		name, rest, ok := strings.Cut(s, `="`)
+		if !ok || name == "" {
+			return el, "", errNotSyslog
+		}
+
+		value, rest, ok := cutQuoted(rest)
+		if !ok {
+			return el, "", errNotSyslog
+		}
+
+		el.Params = append(el.Params, sdParam{Name: name, Value: value})
+		s = rest
+	}
+}
+
+// cutQuoted reads a PARAM-VALUE up to its closing quote, undoing
+// the \" \\ and \] escapes. It returns the value and the remainder
+// following the closing quote.
+func cutQuoted(s string) (string, string, bool) {
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) && strings.IndexByte(`"\]`, s[i+1]) >= 0 {
+				i++
+			}
+		case '"':
+			return b.String(), s[i+1:], true
+		}
+
+		b.WriteByte(s[i])
+	}
+
+	return "", "", false
+}
+
+// parseTime parses an RFC 3339 or RFC 3164 timestamp. RFC 3164
+// timestamps carry no year, so the year is taken from now; a date
+// that would land in the future belongs to the previous year. A
+// zero time is returned if the timestamp can't be parsed.
+func parseTime(ts string, now time.Time) time.Time {
+	if t, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+		return t
+	}
+
+	t, err := time.ParseInLocation(time.Stamp, ts, now.Location())
+	if err != nil {
+		return time.Time{}
+	}
+
+	t = t.AddDate(now.Year(), 0, 0)
+	if t.After(now.Add(24 * time.Hour)) {
+		t = t.AddDate(-1, 0, 0)
+	}
+
+	return t
+}
+
+// nilValue converts the RFC 5424 NILVALUE "-" to an empty string.
+func nilValue(s string) string {
+	if s == "-" {
+		return ""
+	}
+
+	return s
+}
@@ -0,0 +1,162 @@
+// Format RFC 3164 and RFC 5424 syslog lines
+package syslog
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/assistcontrol/muxytail/color"
+	"github.com/assistcontrol/muxytail/config"
+	"github.com/assistcontrol/muxytail/formatter"
+)
+
+// defaultProgramWidth is the width the program column is padded to
+// when config.SyslogConfig.ProgramWidth is unset.
+const defaultProgramWidth = 16
+
+// severityNames are the syslog severity keywords, indexed by
+// severity value.
+var severityNames = [...]string{
+	"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug",
+}
+
+// struct colorizer holds the colorizer functions generated from
+// the passed syslog config
+type colorizer struct {
+	Bracket        color.Colorizer
+	Host           color.Colorizer
+	Program        color.Colorizer
+	PID            color.Colorizer
+	StructuredData color.Colorizer
+	Severity       [len(severityNames)]color.Colorizer
+
+	programWidth int
+	convertTime  bool
+}
+
+// Format parses a syslog line, formats it into a legible style
+// with an aligned program column, and colorizes it. It returns the
+// formatted line and a boolean indicating whether formatting was
+// successful. If bool is false, the input was not a syslog line.
+func (clr *colorizer) Format(in string) (string, bool) {
+	e := &formatter.Entry{Raw: in, Text: in}
+	ok := clr.FormatEntry(e)
+
+	return e.Text, ok
+}
+
+// FormatEntry is Format for a formatter.Entry. Besides rendering
+// the text, it fills in the entry's time, level and fields from the
+// parsed message.
+func (clr *colorizer) FormatEntry(e *formatter.Entry) bool {
+	msg, err := parse(e.Text)
+	if err != nil {
+		return false
+	}
+
+	clr.FillEntry(e, msg)
+
+	return true
+}
+
+// FillEntry renders m into e, and fills in e's time, level and
+// fields from it. It is exported so that formatters for other
+// syslog-like sources can share the same rendering.
+func (clr *colorizer) FillEntry(e *formatter.Entry, m *Message) {
+	e.Text = clr.formatMessage(m)
+	e.Time = m.Time
+	e.Level = m.Level()
+	e.Fields = m.Fields()
+}
+
+// formatMessage takes a parsed Message struct, formats it, and
+// colorizes it.
+func (clr *colorizer) formatMessage(m *Message) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s%s%s %s ",
+		clr.Bracket("["), clr.timestamp(m), clr.Bracket("]"),
+		clr.Host(m.Host),
+	)
+
+	// Pad before colorizing so escape codes don't skew the width
+	tag := m.App
+	program := clr.Program(m.App)
+	if m.PID != "" {
+		tag += "[" + m.PID + "]"
+		program += clr.Bracket("[") + clr.PID(m.PID) + clr.Bracket("]")
+	}
+	b.WriteString(program)
+	b.WriteString(strings.Repeat(" ", max(clr.programWidth-len(tag), 0)))
+
+	for _, el := range m.SD {
+		b.WriteString(" ")
+		b.WriteString(clr.StructuredData(el))
+	}
+
+	b.WriteString(" ")
+	b.WriteString(clr.severity(m)(m.Msg))
+
+	return b.String()
+}
+
+// timestamp returns the message's timestamp, rendered with
+// formatter.FormatTime if configured to do so.
+func (clr *colorizer) timestamp(m *Message) string {
+	if clr.convertTime && !m.Time.IsZero() {
+		return formatter.FormatTime(m.Time)
+	}
+
+	return m.Timestamp
+}
+
+// severity returns the colorizer for the message's severity. Lines
+// without a priority are left uncolored.
+func (clr *colorizer) severity(m *Message) color.Colorizer {
+	sev := m.Severity()
+	if sev < 0 {
+		return fmt.Sprint
+	}
+
+	return clr.Severity[sev]
+}
+
+// sdElement.String renders the element as [id name=value ...].
+func (el sdElement) String() string {
+	parts := []string{el.ID}
+	for _, p := range el.Params {
+		parts = append(parts, p.Name+"="+p.Value)
+	}
+
+	return "[" + strings.Join(parts, " ") + "]"
+}
+
+// init makes the formatter available as type "syslog".
+func init() {
+	formatter.Register("syslog", formatter.Configured(New))
+}
+
+// New takes a config.SyslogConfig struct specifying color strings,
+// and returns a *colorizer struct of colorization functions that
+// is capable of parsing and formatting syslog lines.
+func New(conf config.SyslogConfig) *colorizer {
+	c := &colorizer{
+		Bracket:        color.GenerateColorizer(conf.Bracket),
+		Host:           color.GenerateColorizer(conf.Host),
+		Program:        color.GenerateColorizer(conf.Program),
+		PID:            color.GenerateColorizer(conf.PID),
+		StructuredData: color.GenerateColorizer(conf.StructuredData),
+		programWidth:   defaultProgramWidth,
+		convertTime:    conf.ConvertTime,
+	}
+
+	if conf.ProgramWidth != nil {
+		c.programWidth = *conf.ProgramWidth
+	}
+
+	for sev, name := range severityNames {
+		c.Severity[sev] = color.GenerateColorizer(conf.Severity[name])
+	}
+
+	return c
+}
@@ -0,0 +1,300 @@
+package syslog
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/assistcontrol/muxytail/config"
+	"github.com/assistcontrol/muxytail/formatter"
+	termcolor "github.com/gookit/color"
+)
+
+func TestFormat(t *testing.T) {
+	width := 12
+	clr := New(config.SyslogConfig{ProgramWidth: &width})
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		success  bool
+	}{
+		{
+			name:     "RFC 3164 with PID",
+			input:    "Oct  1 02:12:20 myhost sshd[1234]: Accepted publickey",
+			expected: "[Oct  1 02:12:20] myhost sshd[1234]   Accepted publickey",
+			success:  true,
+		},
+		{
+			name:     "RFC 3164 without PID",
+			input:    "Oct 11 22:14:15 myhost kernel: eth0 link up",
+			expected: "[Oct 11 22:14:15] myhost kernel       eth0 link up",
+			success:  true,
+		},
+		{
+			name:     "rsyslog RFC 3339 timestamp",
+			input:    "2022-10-01T02:12:20.123456+00:00 myhost cron[99]: (root) CMD (true)",
+			expected: "[2022-10-01T02:12:20.123456+00:00] myhost cron[99]     (root) CMD (true)",
+			success:  true,
+		},
+		{
+			name:     "RFC 5424 with structured data",
+			input:    `<165>1 2003-10-11T22:14:15.003Z mymachine evntslog - ID47 [exampleSDID@32473 iut="3" eventSource="Application"] An application event`,
+			expected: "[2003-10-11T22:14:15.003Z] mymachine evntslog     [exampleSDID@32473 iut=3 eventSource=Application] An application event",
+			success:  true,
+		},
+		{
+			name:     "Program wider than column",
+			input:    "Oct 11 22:14:15 myhost systemd-networkd[412]: Configured",
+			expected: "[Oct 11 22:14:15] myhost systemd-networkd[412] Configured",
+			success:  true,
+		},
+		{
+			name:     "Not syslog",
+			input:    "6: Unmatched string",
+			expected: "6: Unmatched string",
+			success:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, success := clr.Format(tt.input)
+			if success != tt.success {
+				t.Errorf("expected success %v, got %v", tt.success, success)
+			}
+			if result != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestProgramWidth(t *testing.T) {
+	zero := 0
+
+	tests := []struct {
+		name     string
+		width    *int
+		expected string
+	}{
+		{"Unset", nil, "[Oct 11 22:14:15] myhost kernel           eth0 link up"},
+		{"Zero", &zero, "[Oct 11 22:14:15] myhost kernel eth0 link up"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clr := New(config.SyslogConfig{ProgramWidth: tt.width})
+			if result, _ := clr.Format("Oct 11 22:14:15 myhost kernel: eth0 link up"); result != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestConvertTime(t *testing.T) {
+	if err := formatter.SetTime("15:04:05.000", "UTC"); err != nil {
+		t.Fatal(err)
+	}
+	defer formatter.SetTime("", "")
+
+	// The same layout and zone as caddy's timestamps
+	clr := New(config.SyslogConfig{ConvertTime: true})
+	result, _ := clr.Format("2022-10-01T04:12:20.123+02:00 myhost cron: tick")
+	if expected := "[02:12:20.123] myhost cron             tick"; result != expected {
+		t.Errorf("expected %q, got %q", expected, result)
+	}
+}
+
+func TestSeverityColor(t *testing.T) {
+	clr := New(config.SyslogConfig{
+		Severity: map[string]string{"err": "#FF0000"},
+	})
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "Colored severity",
+			input:    "<3>Oct 11 22:14:15 myhost app: boom",
+			expected: termcolor.HEXStyle("#FF0000").Sprint("boom"),
+		},
+		{
+			name:     "Unconfigured severity",
+			input:    "<6>Oct 11 22:14:15 myhost app: fine",
+			expected: "fine",
+		},
+		{
+			name:     "No priority",
+			input:    "Oct 11 22:14:15 myhost app: plain",
+			expected: "plain",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := parse(tt.input)
+			if err != nil {
+				t.Fatalf("parse() error = %v", err)
+			}
+			if got := clr.severity(m)(m.Msg); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    Message
+		wantErr bool
+	}{
+		{
+			name:  "RFC 5424 NILVALUEs",
+			input: "<34>1 - - - - - - hello",
+			want:  Message{Priority: 34, Msg: "hello"},
+		},
+		{
+			name:  "RFC 5424 escaped param",
+			input: `<34>1 2003-10-11T22:14:15Z host app 42 - [id a="x\"y\]z"]`,
+			want: Message{
+				Priority:  34,
+				Timestamp: "2003-10-11T22:14:15Z",
+				Host:      "host",
+				App:       "app",
+				PID:       "42",
+				SD:        []sdElement{{ID: "id", Params: []sdParam{{"a", `x"y]z`}}}},
+			},
+		},
+		{
+			name:    "Unterminated structured data",
+			input:   `<34>1 2003-10-11T22:14:15Z host app 42 - [id a="x`,
+			wantErr: true,
+		},
+		{
+			name:    "Priority out of range",
+			input:   "<192>Oct 11 22:14:15 myhost app: msg",
+			wantErr: true,
+		},
+		{
+			name:    "Missing tag",
+			input:   "Oct 11 22:14:15 last message repeated 2 times",
+			wantErr: true,
+		},
+		{
+			name:    "Application log with an RFC 3339 timestamp",
+			input:   "2024-05-01T12:00:00Z INFO server: ERROR started",
+			wantErr: true,
+		},
+		{
+			name:  "RFC 3339 timestamp with a priority",
+			input: "<14>2024-05-01T12:00:00Z HOST app: msg",
+			want:  Message{Priority: 14, Timestamp: "2024-05-01T12:00:00Z", Host: "HOST", App: "app", Msg: "msg"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parse(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			got.Time = time.Time{}
+			if got.Priority != tt.want.Priority || got.Timestamp != tt.want.Timestamp ||
+				got.Host != tt.want.Host || got.App != tt.want.App ||
+				got.PID != tt.want.PID || got.Msg != tt.want.Msg ||
+				len(got.SD) != len(tt.want.SD) {
+				t.Fatalf("parse() = %+v, want %+v", got, tt.want)
+			}
+			for i := range got.SD {
+				if got.SD[i].String() != tt.want.SD[i].String() {
+					t.Errorf("SD[%d] = %v, want %v", i, got.SD[i], tt.want.SD[i])
+				}
+			}
+		})
+	}
+}
+
+func Test_parseTime(t *testing.T) {
+	now := time.Date(2024, time.January, 2, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		input string
+		want  time.Time
+	}{
+		{
+			name:  "RFC 3164 this year",
+			input: "Jan  1 10:00:00",
+			want:  time.Date(2024, time.January, 1, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "RFC 3164 last year",
+			input: "Dec 31 23:59:59",
+			want:  time.Date(2023, time.December, 31, 23, 59, 59, 0, time.UTC),
+		},
+		{
+			name:  "RFC 3339",
+			input: "2024-01-02T03:04:05.5Z",
+			want:  time.Date(2024, time.January, 2, 3, 4, 5, 500000000, time.UTC),
+		},
+		{
+			name:  "Unparseable",
+			input: "yesterday",
+			want:  time.Time{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseTime(tt.input, now); !got.Equal(tt.want) {
+				t.Errorf("parseTime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatEntry(t *testing.T) {
+	clr := New(config.SyslogConfig{})
+
+	e := formatter.NewEntry("/var/log/messages", `<11>1 2003-10-11T22:14:15.003Z mymachine evntslog 42 ID47 [exampleSDID@32473 iut="3"] disk failing`)
+	if !clr.FormatEntry(e) {
+		t.Fatal("FormatEntry() failed")
+	}
+
+	if want := time.Date(2003, 10, 11, 22, 14, 15, 3e6, time.UTC); !e.Time.Equal(want) {
+		t.Errorf("Time = %v, want %v", e.Time, want)
+	}
+	if e.Level != "error" {
+		t.Errorf("Level = %q, want %q", e.Level, "error")
+	}
+
+	fields := map[string]any{
+		"host":              "mymachine",
+		"app":               "evntslog",
+		"pid":               "42",
+		"msgid":             "ID47",
+		"msg":               "disk failing",
+		"facility":          1,
+		"severity":          3,
+		"exampleSDID@32473": map[string]any{"iut": "3"},
+	}
+	if !reflect.DeepEqual(e.Fields, fields) {
+		t.Errorf("Fields = %v, want %v", e.Fields, fields)
+	}
+
+	// Unparseable entries are left alone
+	e = formatter.NewEntry("test", "not syslog")
+	if clr.FormatEntry(e) || e.Text != "not syslog" || e.Fields != nil {
+		t.Errorf("FormatEntry() modified unparseable entry: %+v", e)
+	}
+}
@@ -0,0 +1,54 @@
+package formatter
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// TimeFormat is the default layout for displayed date/times.
+const TimeFormat = "2/Jan 15:04:05"
+
+// struct timeSetting is the layout and zone of displayed timestamps.
+type timeSetting struct {
+	layout string
+	loc    *time.Location
+}
+
+// displayTime is shared by formatters so that timestamps from
+// different sources line up with each other. Unset, it is
+// TimeFormat in the local zone.
+var displayTime atomic.Pointer[timeSetting]
+
+// SetTime sets the layout and zone that FormatTime renders
+// timestamps in. An empty layout means TimeFormat. The zone may be
+// "local" (or empty), "UTC", or an IANA zone name.
+func SetTime(layout, zone string) error {
+	s := &timeSetting{layout: layout, loc: time.Local}
+	if s.layout == "" {
+		s.layout = TimeFormat
+	}
+
+	switch zone {
+	case "", "local", "Local":
+	default:
+		loc, err := time.LoadLocation(zone)
+		if err != nil {
+			return err
+		}
+		s.loc = loc
+	}
+
+	displayTime.Store(s)
+
+	return nil
+}
+
+// FormatTime renders t in the layout and zone set by SetTime.
+func FormatTime(t time.Time) string {
+	s := displayTime.Load()
+	if s == nil {
+		return t.Local().Format(TimeFormat)
+	}
+
+	return t.In(s.loc).Format(s.layout)
+}
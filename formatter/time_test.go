@@ -0,0 +1,38 @@
+package formatter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatTime(t *testing.T) {
+	defer SetTime("", "")
+
+	ts := time.Date(2022, time.October, 1, 2, 12, 20, 263000000, time.UTC)
+
+	tests := []struct {
+		name     string
+		layout   string
+		zone     string
+		expected string
+	}{
+		{"Milliseconds in UTC", "15:04:05.000", "UTC", "02:12:20.263"},
+		{"Named zone", "15:04:05 MST", "America/New_York", "22:12:20 EDT"},
+		{"Default layout", "", "UTC", "1/Oct 02:12:20"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := SetTime(tt.layout, tt.zone); err != nil {
+				t.Fatal(err)
+			}
+			if got := FormatTime(ts); got != tt.expected {
+				t.Errorf("FormatTime() = %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+
+	if err := SetTime("", "Nowhere/Special"); err == nil {
+		t.Error("expected an error for an unknown zone")
+	}
+}
@@ -5,8 +5,11 @@ go 1.24
 require (
 	atomicgo.dev/keyboard v0.2.9
 	github.com/gookit/color v1.5.4
+	github.com/mattn/go-runewidth v0.0.13
 	github.com/mileusna/useragent v1.3.5
 	github.com/nxadm/tail v1.4.11
+	github.com/oschwald/maxminddb-golang v1.13.1
+	go.starlark.net v0.0.0-20260210143700-b62fd896b91b
 	golang.org/x/term v0.32.0
 	gopkg.in/yaml.v3 v3.0.1
 )
@@ -14,6 +17,7 @@ require (
 require (
 	github.com/containerd/console v1.0.5 // indirect
 	github.com/fsnotify/fsnotify v1.9.0 // indirect
+	github.com/rivo/uniseg v0.2.0 // indirect
 	github.com/xo/terminfo v0.0.0-20220910002029-abceb7e1c41e // indirect
 	golang.org/x/sys v0.33.0 // indirect
 	gopkg.in/tomb.v1 v1.0.0-20141024135613-dd632973f1e7 // indirect
@@ -0,0 +1,123 @@
+package multiline
+
+import (
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/assistcontrol/muxytail/config"
+)
+
+const (
+	// defaultTimeout is how long a pending entry waits for another
+	// line before it is sent on.
+	defaultTimeout = 250 * time.Millisecond
+
+	// defaultMaxLines caps the size of an entry, so that a pattern
+	// that never matches can't swallow a whole file.
+	defaultMaxLines = 500
+)
+
+// struct Joiner assembles consecutive lines into logical entries,
+// so that stack traces and the like stay together. A line is
+// appended to the pending entry if it matches Continuation, or if
+// Start is set and it doesn't match Start. Every other line begins
+// a new entry.
+type Joiner struct {
+	Start        *regexp.Regexp
+	Continuation *regexp.Regexp
+	Timeout      time.Duration
+	MaxLines     int
+}
+
+// New returns a Joiner for the provided rule, or nil if the rule
+// is empty.
+func New(conf config.MultilineConfig) *Joiner {
+	if conf.Start == "" && conf.Continuation == "" {
+		return nil
+	}
+
+	j := &Joiner{
+		Timeout:  conf.Timeout,
+		MaxLines: conf.MaxLines,
+	}
+
+	var err error
+	if conf.Start != "" {
+		if j.Start, err = regexp.Compile(conf.Start); err != nil {
+			log.Fatalln("multiline start:", err)
+		}
+	}
+	if conf.Continuation != "" {
+		if j.Continuation, err = regexp.Compile(conf.Continuation); err != nil {
+			log.Fatalln("multiline continuation:", err)
+		}
+	}
+
+	if j.Timeout <= 0 {
+		j.Timeout = defaultTimeout
+	}
+	if j.MaxLines <= 0 {
+		j.MaxLines = defaultMaxLines
+	}
+
+	return j
+}
+
+// Join reads lines from in and sends assembled entries, with their
+// lines separated by newlines, up the returned channel. An entry is
+// sent once the next entry begins, once no line has arrived for
+// j.Timeout, or once it reaches j.MaxLines. The returned channel is
+// closed after in is. A nil *Joiner passes lines through as is.
+func (j *Joiner) Join(in <-chan string) <-chan string {
+	if j == nil {
+		return in
+	}
+
+	out := make(chan string)
+
+	go func() {
+		defer close(out)
+
+		var pending []string
+		flush := func() {
+			if len(pending) > 0 {
+				out <- strings.Join(pending, "\n")
+				pending = nil
+			}
+		}
+
+		timer := time.NewTimer(j.Timeout)
+		timer.Stop()
+
+		for {
+			select {
+			case line, ok := <-in:
+				if !ok {
+					flush()
+					return
+				}
+
+				if !j.continues(line) || len(pending) >= j.MaxLines {
+					flush()
+				}
+				pending = append(pending, line)
+				timer.Reset(j.Timeout)
+			case <-timer.C:
+				flush()
+			}
+		}
+	}()
+
+	return out
+}
+
+// continues reports whether line continues the pending entry.
+func (j *Joiner) continues(line string) bool {
+	if j.Continuation != nil && j.Continuation.MatchString(line) {
+		return true
+	}
+
+	return j.Start != nil && !j.Start.MatchString(line)
+}
@@ -0,0 +1,123 @@
+package multiline
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/assistcontrol/muxytail/config"
+)
+
+// collect feeds lines through j and returns the assembled entries.
+func collect(j *Joiner, lines []string) []string {
+	in := make(chan string)
+	go func() {
+		defer close(in)
+		for _, line := range lines {
+			in <- line
+		}
+	}()
+
+	var entries []string
+	for entry := range j.Join(in) {
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+func TestJoiner_Join(t *testing.T) {
+	goPanic := []string{
+		"2024/01/02 12:00:00 starting",
+		"panic: runtime error: index out of range",
+		"",
+		"goroutine 1 [running]:",
+		"main.main()",
+		"\t/src/main.go:12 +0x1d",
+		"exit status 2",
+	}
+
+	pyTrace := []string{
+		"INFO ready",
+		"Traceback (most recent call last):",
+		`  File "app.py", line 3, in <module>`,
+		"    main()",
+		"ValueError: boom",
+		"INFO still ready",
+	}
+
+	tests := []struct {
+		name     string
+		conf     config.MultilineConfig
+		lines    []string
+		expected []string
+	}{
+		{
+			name:     "No rule passes lines through",
+			lines:    []string{"a", "b"},
+			expected: []string{"a", "b"},
+		},
+		{
+			name:  "Start pattern",
+			conf:  config.MultilineConfig{Start: `^\d{4}/|^panic:|^exit `},
+			lines: goPanic,
+			expected: []string{
+				"2024/01/02 12:00:00 starting",
+				"panic: runtime error: index out of range\n\ngoroutine 1 [running]:\nmain.main()\n\t/src/main.go:12 +0x1d",
+				"exit status 2",
+			},
+		},
+		{
+			name:  "Continuation pattern",
+			conf:  config.MultilineConfig{Continuation: `^\s|^\w+Error:`},
+			lines: pyTrace,
+			expected: []string{
+				"INFO ready",
+				"Traceback (most recent call last):\n  File \"app.py\", line 3, in <module>\n    main()\nValueError: boom",
+				"INFO still ready",
+			},
+		},
+		{
+			name:     "Max lines",
+			conf:     config.MultilineConfig{Continuation: `^\s`, MaxLines: 2},
+			lines:    []string{"a", " 1", " 2", " 3"},
+			expected: []string{"a\n 1", " 2\n 3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := collect(New(tt.conf), tt.lines)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Join() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestJoiner_Timeout(t *testing.T) {
+	j := New(config.MultilineConfig{
+		Continuation: `^\s`,
+		Timeout:      10 * time.Millisecond,
+	})
+
+	in := make(chan string)
+	out := j.Join(in)
+
+	in <- "first"
+	in <- "  more"
+
+	select {
+	case entry := <-out:
+		if entry != "first\n  more" {
+			t.Errorf("entry = %q, want %q", entry, "first\n  more")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("pending entry was not flushed after the timeout")
+	}
+
+	close(in)
+	if _, ok := <-out; ok {
+		t.Error("output channel not closed")
+	}
+}
@@ -0,0 +1,228 @@
+package stats
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Source states, as shown in the status line. A source that is
+// being read normally has no state.
+const (
+	StateOK        = ""
+	StateWaiting   = "waiting" // for a vanished file to reappear
+	StateReopening = "reopening"
+	StateStopped   = "stopped"
+)
+
+// Messages logged by nxadm/tail, by the state they announce. tail
+// only reports reopens through its logger.
+var tailEvents = []struct {
+	prefix string
+	state  string
+	reopen bool
+}{
+	{"Re-opening ", StateReopening, true},
+	{"Successfully reopened", StateOK, false},
+	{"Waiting for ", StateWaiting, false},
+	{"Stopping tail", StateStopped, false},
+}
+
+// struct Source counts the lines and events of a single file or
+// command. Its methods may be called on a nil *Source, which counts
+// nothing, and from multiple goroutines.
+type Source struct {
+	Name string
+
+	mu      sync.Mutex
+	lines   int
+	last    time.Time // of the last line
+	rate    float64   // lines per second, as of the last tick
+	counted int       // lines as of the last tick
+	ticked  time.Time
+	reopens int
+	errors  int
+	lastErr string
+	state   string
+}
+
+// Line counts a line read at now.
+func (s *Source) Line(now time.Time) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lines++
+	s.last = now
+}
+
+// Error records an error reading the source.
+func (s *Source) Error(err error) {
+	if s == nil || err == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.errors++
+	s.lastErr = err.Error()
+}
+
+// Stopped records that the source has ended, with the error that
+// ended it, if any.
+func (s *Source) Stopped(err error) {
+	if s == nil {
+		return
+	}
+
+	s.Error(err)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.state = StateStopped
+}
+
+// Logger returns a logger for nxadm/tail that records the reopen
+// events it logs, instead of writing them over the display.
+func (s *Source) Logger() *log.Logger {
+	return log.New(eventWriter{s}, "", 0)
+}
+
+// struct eventWriter is the output of a Source's tail logger.
+type eventWriter struct {
+	s *Source
+}
+
+// Write records the event in a message logged by tail. Other
+// messages are ignored.
+func (w eventWriter) Write(p []byte) (int, error) {
+	if w.s == nil {
+		return len(p), nil
+	}
+
+	w.s.mu.Lock()
+	defer w.s.mu.Unlock()
+
+	for _, ev := range tailEvents {
+		if strings.HasPrefix(string(p), ev.prefix) {
+			w.s.state = ev.state
+			if ev.reopen {
+				w.s.reopens++
+			}
+			break
+		}
+	}
+
+	return len(p), nil
+}
+
+// tick updates the line rate as of now.
+func (s *Source) tick(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.ticked.IsZero() {
+		if elapsed := now.Sub(s.ticked).Seconds(); elapsed > 0 {
+			s.rate = float64(s.lines-s.counted) / elapsed
+		}
+	}
+	s.counted = s.lines
+	s.ticked = now
+}
+
+// Summary returns the source's status as of now, such as
+// "access.log 2.5/s 3s ago, 1 reopen".
+func (s *Source) Summary(now time.Time) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	parts := []string{fmt.Sprintf("%s %.1f/s", s.Name, s.rate)}
+
+	if s.last.IsZero() {
+		parts[0] += " idle"
+	} else {
+		parts[0] += " " + since(now.Sub(s.last)) + " ago"
+	}
+	if s.reopens > 0 {
+		parts = append(parts, plural(s.reopens, "reopen"))
+	}
+	if s.errors > 0 {
+		parts = append(parts, plural(s.errors, "error")+": "+s.lastErr)
+	}
+	if s.state != StateOK {
+		parts = append(parts, s.state)
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// since formats an elapsed time coarsely, as in "5s", "3m" or "2h".
+func since(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	default:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+}
+
+// plural returns "1 thing" or "n things".
+func plural(n int, thing string) string {
+	if n == 1 {
+		return "1 " + thing
+	}
+
+	return fmt.Sprintf("%d %ss", n, thing)
+}
+
+// struct Set is the sources being watched, in order.
+type Set struct {
+	mu      sync.Mutex
+	sources []*Source
+}
+
+// Add adds a source to the set, and returns it.
+func (set *Set) Add(name string) *Source {
+	set.mu.Lock()
+	defer set.mu.Unlock()
+
+	s := &Source{Name: name}
+	set.sources = append(set.sources, s)
+
+	return s
+}
+
+// Tick updates the line rates of the sources as of now. It is
+// called periodically, and the rates are averaged since the
+// previous call.
+func (set *Set) Tick(now time.Time) {
+	set.mu.Lock()
+	defer set.mu.Unlock()
+
+	for _, s := range set.sources {
+		s.tick(now)
+	}
+}
+
+// Summary returns the status of every source as of now, for the
+// status line.
+func (set *Set) Summary(now time.Time) string {
+	set.mu.Lock()
+	defer set.mu.Unlock()
+
+	summaries := make([]string, len(set.sources))
+	for i, s := range set.sources {
+		summaries[i] = s.Summary(now)
+	}
+
+	return strings.Join(summaries, " | ")
+}
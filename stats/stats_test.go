@@ -0,0 +1,64 @@
+package stats
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSource(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	set := &Set{}
+	a := set.Add("a.log")
+	b := set.Add("journalctl")
+
+	set.Tick(start)
+	for range 5 {
+		a.Line(start.Add(time.Second))
+	}
+	set.Tick(start.Add(2 * time.Second))
+
+	if got, expected := set.Summary(start.Add(5*time.Second)), "a.log 2.5/s 4s ago | journalctl 0.0/s idle"; got != expected {
+		t.Errorf("Summary() = %q, expected %q", got, expected)
+	}
+
+	// Messages logged by tail
+	logger := a.Logger()
+	logger.Printf("Re-opening moved/deleted file %s ...", "a.log")
+	logger.Printf("Waiting for %s to appear...", "a.log")
+	a.Error(errors.New("too long"))
+
+	tests := []struct {
+		name     string
+		log      string
+		expected string
+	}{
+		{"Waiting", "", "a.log 0.0/s 2m ago, 1 reopen, 1 error: too long, waiting"},
+		{"Reopened", "Successfully reopened a.log", "a.log 0.0/s 2m ago, 1 reopen, 1 error: too long"},
+		{"Truncated", "Re-opening truncated file a.log ...", "a.log 0.0/s 2m ago, 2 reopens, 1 error: too long, reopening"},
+		{"Other message", "Leaky bucket full", "a.log 0.0/s 2m ago, 2 reopens, 1 error: too long, reopening"},
+	}
+	set.Tick(start.Add(3 * time.Second))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.log != "" {
+				logger.Println(tt.log)
+			}
+			if got := a.Summary(start.Add(3 * time.Minute)); got != tt.expected {
+				t.Errorf("Summary() = %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+
+	b.Stopped(nil)
+	if got, expected := b.Summary(start), "journalctl 0.0/s idle, stopped"; got != expected {
+		t.Errorf("Summary() after Stopped = %q, expected %q", got, expected)
+	}
+
+	// A nil source counts nothing
+	var none *Source
+	none.Line(start)
+	none.Error(errors.New("ignored"))
+	none.Stopped(nil)
+	none.Logger().Println("Re-opening truncated file")
+}
@@ -0,0 +1,60 @@
+package view
+
+import "github.com/assistcontrol/muxytail/formatter"
+
+// struct item is a line of output: an entry, or a line that isn't
+// one, such as a separator.
+type item struct {
+	entry *formatter.Entry
+	line  string
+}
+
+// text returns the item's text as printed, before highlighting.
+func (it item) text() string {
+	if it.entry != nil {
+		return it.entry.Text
+	}
+
+	return it.line
+}
+
+// struct history is a ring buffer of the most recent items.
+type history struct {
+	items []item
+	start int // index of the oldest item, once full
+}
+
+// newHistory returns a history holding up to size items.
+func newHistory(size int) *history {
+	return &history{items: make([]item, 0, size)}
+}
+
+// add appends an item, replacing the oldest if the history is full.
+func (h *history) add(it item) {
+	if len(h.items) < cap(h.items) {
+		h.items = append(h.items, it)
+		return
+	}
+
+	h.items[h.start] = it
+	h.start = (h.start + 1) % len(h.items)
+}
+
+// len returns the number of items.
+func (h *history) len() int {
+	return len(h.items)
+}
+
+// at returns the i'th item, counting from the oldest.
+func (h *history) at(i int) item {
+	return h.items[(h.start+i)%len(h.items)]
+}
+
+// all returns the items, oldest first.
+func (h *history) all() []item {
+	all := make([]item, 0, len(h.items))
+	all = append(all, h.items[h.start:]...)
+	all = append(all, h.items[:h.start]...)
+
+	return all
+}
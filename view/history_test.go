@@ -0,0 +1,37 @@
+package view
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestHistory(t *testing.T) {
+	h := newHistory(3)
+
+	texts := func() []string {
+		var s []string
+		for _, it := range h.all() {
+			s = append(s, it.text())
+		}
+		return s
+	}
+
+	h.add(item{line: "a"})
+	h.add(item{line: "b"})
+	if got := texts(); !slices.Equal(got, []string{"a", "b"}) {
+		t.Errorf("all() = %v", got)
+	}
+
+	for _, s := range []string{"c", "d", "e"} {
+		h.add(item{line: s})
+	}
+	if got := texts(); !slices.Equal(got, []string{"c", "d", "e"}) {
+		t.Errorf("all() after wrapping = %v", got)
+	}
+
+	for i, expected := range []string{"c", "d", "e"} {
+		if got := h.at(i).text(); got != expected {
+			t.Errorf("at(%d) = %q, expected %q", i, got, expected)
+		}
+	}
+}
@@ -0,0 +1,312 @@
+package view
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"atomicgo.dev/keyboard/keys"
+	"github.com/assistcontrol/muxytail/color"
+	"github.com/mattn/go-runewidth"
+)
+
+// Terminal control sequences for the pager's screen.
+const (
+	altScreen   = "\x1b[?1049h" // Switch to the alternate screen
+	mainScreen  = "\x1b[?1049l" // Switch back to the main screen
+	clearScreen = "\x1b[H\x1b[2J"
+	resetColor  = "\x1b[0m"
+)
+
+// tabWidth is the distance between the terminal's tab stops.
+const tabWidth = 8
+
+// struct pager is the state of the full-screen pager, which shows
+// the history with the live filter applied and the search
+// highlighted. While it is open, new lines are held as if paused.
+type pager struct {
+	rows  []row
+	top   int // index of the first row on screen
+	match int // index of the current search match, or -1
+}
+
+// struct row is a screen row of the pager. Multi-line entries take
+// a row per line.
+type row struct {
+	text  string
+	plain string // text without colors, for searching
+	mark  bool   // the row is a separator or other non-entry line
+}
+
+// openPager opens the pager at the end of the history. It must be
+// called with v.mu held.
+func (v *View) openPager() {
+	v.clearStatus()
+	fmt.Fprint(v.out, altScreen)
+
+	v.pager = &pager{match: -1}
+	v.buildRows()
+	v.pager.top = len(v.pager.rows)
+	v.renderPager()
+}
+
+// closePager returns to following the live stream, printing any
+// lines held meanwhile unless output is paused. It must be called
+// with v.mu held.
+func (v *View) closePager() {
+	fmt.Fprint(v.out, mainScreen)
+	v.pager = nil
+	v.prompt = ""
+
+	if !v.paused {
+		v.flush()
+	}
+	v.redrawStatus()
+}
+
+// buildRows lays out the history as rows, applying the live
+// filter. It must be called with v.mu held.
+func (v *View) buildRows() {
+	v.pager.rows = v.pager.rows[:0]
+
+	for _, it := range v.history.all() {
+		if it.entry != nil && !v.matches(it.entry.Text) {
+			continue
+		}
+
+		for i, line := range strings.Split(it.text(), "\n") {
+			v.pager.rows = append(v.pager.rows, row{
+				text:  line,
+				plain: color.Strip(line),
+				mark:  it.entry == nil && i == 0,
+			})
+		}
+	}
+}
+
+// pageHeight returns the number of rows on screen, leaving the last
+// line of the terminal for the status line.
+func (v *View) pageHeight() int {
+	_, h := v.Size()
+	return max(h-1, 1)
+}
+
+// pagerKey handles a key while the pager is open:
+//
+//	↑ k, ↓ j            scroll by a line
+//	PgUp b, PgDn Space  scroll by a page
+//	Home g, End G       go to the start or end
+//	[ ]                 go to the previous or next separator
+//	/                   prompts for a regexp to filter with
+//	?                   prompts for a regexp to search for
+//	n N                 go to the next or previous match
+//	q Esc               return to the live stream
+//
+// It must be called with v.mu held.
+func (v *View) pagerKey(key keys.Key) {
+	p := v.pager
+	page := v.pageHeight()
+
+	if v.prompt != "" {
+		kind := v.prompt
+		v.promptKey(key)
+
+		if v.prompt == "" && key.Code == keys.Enter {
+			v.buildRows()
+			p.top, p.match = len(p.rows), -1
+			if kind == promptSearch {
+				v.findMatch(-1)
+			}
+		}
+		v.renderPager()
+		return
+	}
+
+	// Moving other than by n and N starts the next search from the
+	// top of the screen
+	if s := key.String(); key.Code != keys.RuneKey || (s != "n" && s != "N") {
+		p.match = -1
+	}
+
+	switch key.Code {
+	case keys.Escape:
+		v.closePager()
+		return
+	case keys.Up:
+		p.top--
+	case keys.Down:
+		p.top++
+	case keys.PgUp, keys.CtrlB:
+		p.top -= page
+	case keys.PgDown, keys.CtrlF, keys.Space:
+		p.top += page
+	case keys.Home:
+		p.top = 0
+	case keys.End:
+		p.top = len(p.rows)
+	case keys.RuneKey:
+		switch key.String() {
+		case "q":
+			v.closePager()
+			return
+		case "k":
+			p.top--
+		case "j":
+			p.top++
+		case "b":
+			p.top -= page
+		case "g":
+			p.top = 0
+		case "G":
+			p.top = len(p.rows)
+		case "[":
+			v.findMark(-1)
+		case "]":
+			v.findMark(1)
+		case "/":
+			v.prompt, v.input = promptFilter, nil
+		case "?":
+			v.prompt, v.input = promptSearch, nil
+		case "n":
+			v.findMatch(1)
+		case "N":
+			v.findMatch(-1)
+		}
+	}
+
+	v.renderPager()
+}
+
+// findMatch moves to the next (dir 1) or previous (dir -1) row
+// matching the search. It counts from the current match, if there
+// is one, as near the end the screen can't scroll to put a match on
+// top. It must be called with v.mu held.
+func (v *View) findMatch(dir int) {
+	p := v.pager
+	if v.search == nil {
+		return
+	}
+
+	from := p.top
+	if p.match >= 0 {
+		from = p.match
+	}
+
+	i, ok := v.findRow(from, dir, func(r row) bool { return v.search.MatchString(r.plain) })
+	if !ok {
+		v.message = "no more matches"
+		return
+	}
+	p.top, p.match = i, i
+}
+
+// findMark moves to the next (dir 1) or previous (dir -1) separator
+// or other non-entry line. It must be called with v.mu held.
+func (v *View) findMark(dir int) {
+	p := v.pager
+	if i, ok := v.findRow(p.top, dir, func(r row) bool { return r.mark }); ok {
+		p.top = i
+	}
+}
+
+// findRow returns the index of the next (dir 1) or previous (dir -1)
+// row after from satisfying match, and reports whether there was
+// one. It must be called with v.mu held.
+func (v *View) findRow(from, dir int, match func(row) bool) (int, bool) {
+	p := v.pager
+
+	for i := from + dir; i >= 0 && i < len(p.rows); i += dir {
+		if match(p.rows[i]) {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+// renderPager draws the pager's screen. It must be called with v.mu
+// held.
+func (v *View) renderPager() {
+	p := v.pager
+	w, _ := v.Size()
+	page := v.pageHeight()
+
+	p.top = max(min(p.top, len(p.rows)-page), 0)
+	end := min(p.top+page, len(p.rows))
+
+	var b strings.Builder
+	b.WriteString(clearScreen)
+
+	for i := p.top; i < p.top+page; i++ {
+		if i < end {
+			b.WriteString(truncate(v.highlight(p.rows[i].text), w))
+		}
+		b.WriteString("\n")
+	}
+
+	status := fmt.Sprintf("scrollback %d-%d/%d", min(p.top+1, end), end, len(p.rows))
+	if p.match >= 0 {
+		status += fmt.Sprintf(" match at %d", p.match+1)
+	}
+	if len(v.held) > 0 && !v.paused {
+		status += fmt.Sprintf(" (%d new)", len(v.held))
+	}
+	if s := v.status(); s != "" {
+		status += "  " + s
+	}
+	if v.prompt == "" {
+		status += "  (q to return)"
+	}
+	b.WriteString(v.Status(truncate(status, w)))
+
+	fmt.Fprint(v.out, b.String())
+	v.message = ""
+}
+
+// truncate cuts s to width columns of the terminal, keeping its
+// color escape sequences intact. Tabs are expanded to spaces, and
+// wide characters such as CJK take two columns.
+func truncate(s string, width int) string {
+	s, _ = cut(s, width)
+	return s
+}
+
+// cut is truncate, also returning the number of columns s takes.
+func cut(s string, width int) (string, int) {
+	var b strings.Builder
+	visible := 0
+
+	for i := 0; i < len(s); {
+		if s[i] == '\x1b' {
+			end := strings.IndexByte(s[i:], 'm')
+			if end < 0 {
+				break
+			}
+			b.WriteString(s[i : i+end+1])
+			i += end + 1
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		text, w := s[i:i+size], runewidth.RuneWidth(r)
+		if r == '\t' {
+			w = tabWidth - visible%tabWidth
+			text = strings.Repeat(" ", w)
+		}
+
+		if visible+w > width {
+			if r == '\t' {
+				b.WriteString(strings.Repeat(" ", width-visible))
+				visible = width
+			}
+			b.WriteString(resetColor)
+			break
+		}
+
+		b.WriteString(text)
+		i += size
+		visible += w
+	}
+
+	return b.String(), visible
+}
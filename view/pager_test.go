@@ -0,0 +1,195 @@
+package view
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"atomicgo.dev/keyboard/keys"
+	"github.com/assistcontrol/muxytail/formatter"
+	termcolor "github.com/gookit/color"
+)
+
+// pagerRows returns the text of the rows on the pager's screen.
+func pagerRows(v *View) []string {
+	p := v.pager
+	end := min(p.top+v.pageHeight(), len(p.rows))
+
+	var rows []string
+	for _, r := range p.rows[p.top:end] {
+		rows = append(rows, r.plain)
+	}
+
+	return rows
+}
+
+func TestPager(t *testing.T) {
+	v, b := newTestView()
+	v.Size = func() (int, int) { return 40, 4 } // 3 rows and the status line
+
+	for i := 1; i <= 5; i++ {
+		v.Entry(formatter.NewEntry("test", fmt.Sprintf("line %d", i)))
+	}
+	v.Line("----")
+	v.Entry(formatter.NewEntry("test", "trace\n  at main()"))
+	v.Entry(formatter.NewEntry("test", "line 6"))
+
+	press := func(keyStrings ...string) {
+		for _, k := range keyStrings {
+			v.Key(keys.Key{Code: keys.RuneKey, Runes: []rune(k)})
+		}
+	}
+
+	press("b")
+	if v.pager == nil {
+		t.Fatal("b didn't open the pager")
+	}
+	if got := strings.Join(pagerRows(v), "|"); got != "trace|  at main()|line 6" {
+		t.Errorf("rows on opening = %q", got)
+	}
+
+	// Lines arriving meanwhile are held
+	b.Reset()
+	v.Entry(formatter.NewEntry("test", "line 7"))
+	if strings.Contains(b.String(), "line 7") {
+		t.Error("line printed while in the pager")
+	}
+
+	press("g")
+	if got := strings.Join(pagerRows(v), "|"); got != "line 1|line 2|line 3" {
+		t.Errorf("rows at start = %q", got)
+	}
+
+	press("]")
+	if got := pagerRows(v)[0]; got != "----" {
+		t.Errorf("top row after ] = %q", got)
+	}
+
+	press("k", "k")
+	if got := pagerRows(v)[0]; got != "line 4" {
+		t.Errorf("top row after k k = %q", got)
+	}
+
+	// Search from the end, then step back through matches
+	press("?", "l", "i", "n", "e", " ")
+	v.Key(keys.Key{Code: keys.Backspace})
+	v.Key(keys.Key{Code: keys.Enter})
+	if got := strings.Join(pagerRows(v), "|"); got != "trace|  at main()|line 6" {
+		t.Errorf("rows after search = %q", got)
+	}
+	press("N")
+	if got := pagerRows(v)[0]; got != "line 5" {
+		t.Errorf("top row after N = %q", got)
+	}
+
+	// Filtering relays out the history
+	press("/", "[", "2", "4", "]")
+	v.Key(keys.Key{Code: keys.Enter})
+	if got := strings.Join(pagerRows(v), "|"); got != "line 2|line 4|----" {
+		t.Errorf("rows after filter = %q", got)
+	}
+
+	// Leaving releases the held line, which the filter hides
+	b.Reset()
+	press("q")
+	if v.pager != nil {
+		t.Fatal("q didn't close the pager")
+	}
+	if !strings.HasPrefix(b.String(), mainScreen) || strings.Contains(b.String(), "line 7") {
+		t.Errorf("output on closing = %q", b.String())
+	}
+
+	v.Key(keys.Key{Code: keys.Escape})
+	b.Reset()
+	v.Entry(formatter.NewEntry("test", "line 8"))
+	if b.String() != "line 8\n" {
+		t.Errorf("output after closing = %q", b.String())
+	}
+}
+
+func TestPagerMatchesOnLastPage(t *testing.T) {
+	v, _ := newTestView()
+	v.Size = func() (int, int) { return 40, 4 } // 3 rows and the status line
+
+	for i := 1; i <= 6; i++ {
+		v.Entry(formatter.NewEntry("test", fmt.Sprintf("line %d", i)))
+	}
+
+	press := func(keyStrings ...string) {
+		for _, k := range keyStrings {
+			v.Key(keys.Key{Code: keys.RuneKey, Runes: []rune(k)})
+		}
+	}
+
+	press("b", "?", "l", "i", "n", "e")
+	v.Key(keys.Key{Code: keys.Enter})
+	if v.pager.match != 5 {
+		t.Errorf("match after search = %d, expected 5", v.pager.match)
+	}
+
+	// Matches on the last page are stepped through, though the
+	// screen can't scroll to put them on top
+	for _, expected := range []int{4, 3, 2} {
+		press("N")
+		if v.pager.match != expected {
+			t.Errorf("match after N = %d, expected %d", v.pager.match, expected)
+		}
+	}
+	if got := pagerRows(v)[0]; got != "line 3" {
+		t.Errorf("top row after N N N = %q", got)
+	}
+
+	press("n")
+	if v.pager.match != 3 {
+		t.Errorf("match after n = %d, expected 3", v.pager.match)
+	}
+
+	// Scrolling starts the next search from the top of the screen
+	press("g", "n")
+	if v.pager.match != 1 {
+		t.Errorf("match after g n = %d, expected 1", v.pager.match)
+	}
+}
+
+func TestPagerDropped(t *testing.T) {
+	v, b := newTestView()
+	v.PauseBuffer = 2
+
+	v.Key(keys.Key{Code: keys.RuneKey, Runes: []rune("b")})
+	for i := 1; i <= 3; i++ {
+		v.Entry(formatter.NewEntry("test", fmt.Sprintf("line %d", i)))
+	}
+
+	b.Reset()
+	v.Key(keys.Key{Code: keys.RuneKey, Runes: []rune("q")})
+	if !strings.Contains(b.String(), "[1 lines dropped while in the scrollback]") {
+		t.Errorf("output on closing = %q", b.String())
+	}
+}
+
+func Test_truncate(t *testing.T) {
+	red := termcolor.HEXStyle("#FF0000").Sprint
+
+	tests := []struct {
+		name     string
+		input    string
+		width    int
+		expected string
+	}{
+		{"Short", "abc", 5, "abc"},
+		{"Exact", "abcde", 5, "abcde"},
+		{"Long", "abcdef", 5, "abcde" + resetColor},
+		{"Colors don't count", red("abc") + "def", 4, red("abc") + "d" + resetColor},
+		{"Multi-byte", "αβγδ", 2, "αβ" + resetColor},
+		{"Tab", "a\tb", 10, "a       b"},
+		{"Wide", "日本語", 4, "日本" + resetColor},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := truncate(tt.input, tt.width); got != tt.expected {
+				t.Errorf("truncate() = %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+}
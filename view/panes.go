@@ -0,0 +1,368 @@
+package view
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"atomicgo.dev/keyboard/keys"
+	"github.com/assistcontrol/muxytail/color"
+	"github.com/assistcontrol/muxytail/config"
+	"github.com/assistcontrol/muxytail/formatter"
+)
+
+// Pane splits: horizontal stacks the panes top to bottom, vertical
+// puts them side by side.
+const (
+	SplitHorizontal = "horizontal"
+	SplitVertical   = "vertical"
+)
+
+// defaultTitle is the color of the titles of unfocused panes.
+const defaultTitle = "#FFFFFF|#404040"
+
+// Terminal control sequences for the panes' screen.
+const (
+	hideCursor = "\x1b[?25l"
+	showCursor = "\x1b[?25h"
+)
+
+// paneHelp is shown on the last line of the screen.
+const paneHelp = "Tab: focus  ↑↓ PgUp PgDn: scroll  Home End: top/follow  q: quit"
+
+// struct Panes is the full-screen split display: one pane per group
+// of sources, each showing the most recent lines of its sources.
+// The focused pane can be scrolled back through its history. A
+// Panes is safe for use by multiple goroutines.
+type Panes struct {
+	Title   color.Colorizer // titles of unfocused panes
+	Focus   color.Colorizer // title of the focused pane, and the help line
+	Split   string
+	Size    func() (width, height int) // of the terminal
+	panes   []*pane
+	bySrc   map[string]*pane
+	focus   int
+	muted   string // the muted sources, if any
+	sources string // status of the sources, if shown
+	mu      sync.Mutex
+	out     io.Writer
+	width   int // size of the screen as last drawn
+	height  int
+}
+
+// struct pane is a single pane.
+type pane struct {
+	title  string
+	rows   *history // one item per screen row
+	scroll int      // rows scrolled back from the end; 0 follows
+	rect   rect
+}
+
+// struct rect is the area of the screen a pane is drawn in,
+// including its title row. x and y are 1-based.
+type rect struct {
+	x, y, w, h int
+}
+
+// NewPanes returns a Panes display for the given sources (file
+// paths and commands), grouped as configured, that draws on out.
+// Sources not in any group get a pane of their own.
+func NewPanes(conf config.PanesConfig, viewConf config.ViewConfig, sources []string, out io.Writer) *Panes {
+	historySize := viewConf.History
+	if historySize <= 0 {
+		historySize = defaultHistory
+	}
+
+	p := &Panes{
+		Title: color.GenerateColorizer(cmp.Or(conf.Title, defaultTitle)),
+		Focus: color.GenerateColorizer(cmp.Or(viewConf.Status, defaultStatus)),
+		Split: cmp.Or(conf.Split, SplitHorizontal),
+		Size:  terminalSize(out),
+		bySrc: make(map[string]*pane),
+		out:   out,
+	}
+
+	add := func(title string, srcs ...string) {
+		pn := &pane{title: title, rows: newHistory(historySize)}
+		p.panes = append(p.panes, pn)
+		for _, src := range srcs {
+			p.bySrc[src] = pn
+		}
+	}
+
+	for _, group := range conf.Groups {
+		add(cmp.Or(group.Title, strings.Join(group.Sources, ", ")), group.Sources...)
+	}
+	for _, src := range sources {
+		if _, ok := p.bySrc[src]; !ok {
+			add(src, src)
+		}
+	}
+	if len(p.panes) == 0 {
+		add("")
+	}
+
+	fmt.Fprint(out, altScreen, hideCursor)
+	p.render()
+
+	return p
+}
+
+// Entry adds an entry to the pane of its source, or to the first
+// pane if its source has none.
+func (p *Panes) Entry(e *formatter.Entry) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pn, ok := p.bySrc[e.Source]
+	if !ok {
+		pn = p.panes[0]
+	}
+
+	p.add(pn, e.Text)
+	if !p.resized() {
+		p.drawPane(pn)
+		return
+	}
+
+	p.render()
+}
+
+// Line adds a line that isn't an entry, such as a separator, to
+// every pane.
+func (p *Panes) Line(s string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, pn := range p.panes {
+		p.add(pn, s)
+	}
+
+	p.render()
+}
+
+// add adds text to a pane, a row per line. A pane that is scrolled
+// back stays where it is. It must be called with p.mu held.
+func (p *Panes) add(pn *pane, text string) {
+	for _, line := range strings.Split(text, "\n") {
+		pn.rows.add(item{line: line})
+		if pn.scroll > 0 {
+			pn.scroll = min(pn.scroll+1, pn.rows.len())
+		}
+	}
+}
+
+// SetSources sets the status of the sources, which is shown on the
+// help line.
+func (p *Panes) SetSources(s string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.sources = s
+	if p.resized() {
+		p.render()
+		return
+	}
+
+	fmt.Fprint(p.out, p.helpLine())
+}
+
+// SetMuted sets the list of muted sources, which is shown on the
+// help line.
+func (p *Panes) SetMuted(s string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.muted = s
+	if p.resized() {
+		p.render()
+		return
+	}
+
+	fmt.Fprint(p.out, p.helpLine())
+}
+
+// Key handles the keys of the panes, and reports whether it used
+// the key:
+//
+//	Tab, Shift-Tab  focus the next or previous pane
+//	↑ ↓             scroll the focused pane by a line
+//	PgUp PgDn       scroll the focused pane by a page
+//	Home            go to the start of the focused pane
+//	End             follow the focused pane again
+func (p *Panes) Key(key keys.Key) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pn := p.panes[p.focus]
+	page := max(pn.rect.h-1, 1)
+
+	switch key.Code {
+	case keys.Tab:
+		p.focus = (p.focus + 1) % len(p.panes)
+	case keys.ShiftTab:
+		p.focus = (p.focus + len(p.panes) - 1) % len(p.panes)
+	case keys.Up:
+		pn.scroll++
+	case keys.Down:
+		pn.scroll--
+	case keys.PgUp:
+		pn.scroll += page
+	case keys.PgDown:
+		pn.scroll -= page
+	case keys.Home:
+		pn.scroll = pn.rows.len()
+	case keys.End:
+		pn.scroll = 0
+	default:
+		return false
+	}
+
+	p.render()
+
+	return true
+}
+
+// Close restores the terminal.
+func (p *Panes) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	fmt.Fprint(p.out, showCursor, mainScreen)
+}
+
+// resized reports whether the terminal has changed size since the
+// screen was last drawn. It must be called with p.mu held.
+func (p *Panes) resized() bool {
+	w, h := p.Size()
+	return w != p.width || h != p.height
+}
+
+// layout divides the screen between the panes, leaving the last
+// line for the help line. It must be called with p.mu held.
+func (p *Panes) layout() {
+	p.width, p.height = p.Size()
+	n := len(p.panes)
+
+	if p.Split == SplitVertical {
+		// Columns, with a one-column border between them
+		avail := p.width - (n - 1)
+		x := 1
+		for i, pn := range p.panes {
+			w := avail / n
+			if i < avail%n {
+				w++
+			}
+			pn.rect = rect{x: x, y: 1, w: max(w, 1), h: max(p.height-1, 1)}
+			x += w + 1
+		}
+		return
+	}
+
+	avail := p.height - 1
+	y := 1
+	for i, pn := range p.panes {
+		h := avail / n
+		if i < avail%n {
+			h++
+		}
+		pn.rect = rect{x: 1, y: y, w: p.width, h: max(h, 1)}
+		y += h
+	}
+}
+
+// render draws the whole screen. It must be called with p.mu held.
+func (p *Panes) render() {
+	p.layout()
+
+	var b strings.Builder
+	b.WriteString(clearScreen)
+
+	for i, pn := range p.panes {
+		p.writePane(&b, pn)
+
+		if p.Split == SplitVertical && i < len(p.panes)-1 {
+			for y := pn.rect.y; y < pn.rect.y+pn.rect.h; y++ {
+				fmt.Fprintf(&b, "\x1b[%d;%dH│", y, pn.rect.x+pn.rect.w)
+			}
+		}
+	}
+
+	b.WriteString(p.helpLine())
+
+	fmt.Fprint(p.out, b.String())
+}
+
+// helpLine returns the help line, positioned at the bottom of the
+// screen, followed by the muted sources and the status of the
+// sources. It must be called with p.mu held.
+func (p *Panes) helpLine() string {
+	s := paneHelp
+	for _, part := range []string{p.muted, p.sources} {
+		if part != "" {
+			s += "  " + part
+		}
+	}
+
+	return fmt.Sprintf("\x1b[%d;1H%s", p.height, p.Focus(pad(s, p.width)))
+}
+
+// drawPane redraws a single pane. It must be called with p.mu held.
+func (p *Panes) drawPane(pn *pane) {
+	var b strings.Builder
+	p.writePane(&b, pn)
+
+	fmt.Fprint(p.out, b.String())
+}
+
+// writePane writes the title and visible rows of a pane to b. It
+// must be called with p.mu held.
+func (p *Panes) writePane(b *strings.Builder, pn *pane) {
+	r := pn.rect
+	rows := pn.visible()
+
+	title := " " + pn.title + " "
+	if pn.scroll > 0 {
+		title += fmt.Sprintf("[-%d] ", pn.scroll)
+	}
+	titleColor := p.Title
+	if pn == p.panes[p.focus] {
+		titleColor = p.Focus
+	}
+	fmt.Fprintf(b, "\x1b[%d;%dH%s", r.y, r.x, titleColor(pad(title, r.w)))
+
+	for i := range r.h - 1 {
+		var s string
+		if i < len(rows) {
+			s = rows[i]
+		}
+		fmt.Fprintf(b, "\x1b[%d;%dH%s", r.y+1+i, r.x, pad(s, r.w))
+	}
+}
+
+// visible returns the rows of a pane that fit on screen, keeping
+// its scroll within the history. Only those rows are read, as it
+// runs for every line. It must be called with p.mu held.
+func (pn *pane) visible() []string {
+	n := pn.rows.len()
+	height := pn.rect.h - 1
+
+	pn.scroll = max(min(pn.scroll, n-height), 0)
+	end := n - pn.scroll
+
+	lines := make([]string, 0, max(height, 0))
+	for i := max(end-height, 0); i < end; i++ {
+		lines = append(lines, pn.rows.at(i).line)
+	}
+
+	return lines
+}
+
+// pad truncates or pads s with spaces to exactly width columns, so
+// that it overwrites whatever was on screen.
+func pad(s string, width int) string {
+	s, visible := cut(s, width)
+	return s + strings.Repeat(" ", max(width-visible, 0))
+}
@@ -0,0 +1,145 @@
+package view
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"atomicgo.dev/keyboard/keys"
+	"github.com/assistcontrol/muxytail/config"
+	"github.com/assistcontrol/muxytail/formatter"
+)
+
+// newTestPanes returns a Panes on a 40x9 screen, and the buffer it
+// writes to.
+func newTestPanes(conf config.PanesConfig, sources ...string) (*Panes, *bytes.Buffer) {
+	b := &bytes.Buffer{}
+	p := NewPanes(conf, config.ViewConfig{}, sources, b)
+	p.Size = func() (int, int) { return 40, 9 }
+	p.render()
+
+	return p, b
+}
+
+func TestPanes(t *testing.T) {
+	p, _ := newTestPanes(config.PanesConfig{
+		Groups: []config.PaneGroup{
+			{Title: "web", Sources: []string{"a.log", "b.log"}},
+		},
+	}, "a.log", "b.log", "journalctl -f")
+
+	var titles []string
+	for _, pn := range p.panes {
+		titles = append(titles, pn.title)
+	}
+	if got := strings.Join(titles, "|"); got != "web|journalctl -f" {
+		t.Fatalf("pane titles = %q", got)
+	}
+
+	// 8 rows above the help line, 4 per pane including its title
+	for i, expected := range []rect{{1, 1, 40, 4}, {1, 5, 40, 4}} {
+		if got := p.panes[i].rect; got != expected {
+			t.Errorf("pane %d rect = %v, expected %v", i, got, expected)
+		}
+	}
+
+	p.Entry(formatter.NewEntry("a.log", "a1"))
+	p.Entry(formatter.NewEntry("journalctl -f", "j1"))
+	p.Entry(formatter.NewEntry("b.log", "b1\nb2"))
+	p.Entry(formatter.NewEntry("c.log", "c1")) // No pane; goes to the first
+	p.Line("----")
+
+	tests := []struct {
+		name     string
+		pane     int
+		expected string
+	}{
+		{"Grouped pane", 0, "b2|c1|----"},
+		{"Own pane", 1, "j1|----"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := strings.Join(p.panes[tt.pane].visible(), "|"); got != tt.expected {
+				t.Errorf("rows = %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+
+	// Scrolling applies to the focused pane
+	p.Key(keys.Key{Code: keys.Tab})
+	p.Key(keys.Key{Code: keys.Tab})
+	if p.focus != 0 {
+		t.Fatalf("focus after two tabs = %d", p.focus)
+	}
+	p.Key(keys.Key{Code: keys.Up})
+	if got := strings.Join(p.panes[0].visible(), "|"); got != "b1|b2|c1" {
+		t.Errorf("rows after up = %q", got)
+	}
+
+	// A scrolled pane stays put as lines arrive
+	p.Entry(formatter.NewEntry("a.log", "a2"))
+	if got := strings.Join(p.panes[0].visible(), "|"); got != "b1|b2|c1" {
+		t.Errorf("rows after a new line = %q", got)
+	}
+
+	p.Key(keys.Key{Code: keys.Home})
+	if got := strings.Join(p.panes[0].visible(), "|"); got != "a1|b1|b2" {
+		t.Errorf("rows after home = %q", got)
+	}
+
+	p.Key(keys.Key{Code: keys.End})
+	if got := strings.Join(p.panes[0].visible(), "|"); got != "c1|----|a2" {
+		t.Errorf("rows after end = %q", got)
+	}
+
+	if p.Key(keys.Key{Code: keys.RuneKey, Runes: []rune("q")}) {
+		t.Error("q was used by the panes")
+	}
+}
+
+func TestPanesVertical(t *testing.T) {
+	p, b := newTestPanes(config.PanesConfig{Split: SplitVertical}, "a", "b", "c")
+
+	// 38 columns between the panes, after two borders
+	for i, expected := range []rect{{1, 1, 13, 8}, {15, 1, 13, 8}, {29, 1, 12, 8}} {
+		if got := p.panes[i].rect; got != expected {
+			t.Errorf("pane %d rect = %v, expected %v", i, got, expected)
+		}
+	}
+
+	if !strings.Contains(b.String(), "\x1b[1;14H│") {
+		t.Error("no border between the first panes")
+	}
+
+	// A tab is expanded, so the line stops short of the border
+	b.Reset()
+	p.Entry(formatter.NewEntry("a", "x\ty\t\tz"))
+	if !strings.Contains(b.String(), "\x1b[2;1Hx       y    \x1b[0m") {
+		t.Errorf("output = %q", b.String())
+	}
+}
+
+func Test_pad(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       string
+		width    int
+		expected string
+	}{
+		{"Short", "ab", 4, "ab  "},
+		{"Exact", "abcd", 4, "abcd"},
+		{"Long", "abcdef", 4, "abcd\x1b[0m"},
+		{"Colored", "\x1b[31mab\x1b[0m", 4, "\x1b[31mab\x1b[0m  "},
+		{"Tab", "a\tb", 12, "a       b   "},
+		{"Tab cut", "ab\tc", 5, "ab   \x1b[0m"},
+		{"Wide", "日本", 5, "日本 "},
+		{"Wide cut", "日本語", 5, "日本\x1b[0m "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pad(tt.in, tt.width); got != tt.expected {
+				t.Errorf("pad(%q, %d) = %q, expected %q", tt.in, tt.width, got, tt.expected)
+			}
+		})
+	}
+}
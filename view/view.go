@@ -0,0 +1,442 @@
+package view
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"atomicgo.dev/keyboard/keys"
+	"github.com/assistcontrol/muxytail/color"
+	"github.com/assistcontrol/muxytail/config"
+	"github.com/assistcontrol/muxytail/formatter"
+	"github.com/assistcontrol/muxytail/formatter/regex"
+	"golang.org/x/term"
+)
+
+// Default colors for search matches and the status line.
+const (
+	defaultHighlight = "#000000|#FFFF00"
+	defaultStatus    = "#000000|#C0C0C0"
+)
+
+// Default sizes of the line buffers, if the config doesn't say:
+// lines held while paused, and lines kept for the pager.
+const (
+	defaultPauseBuffer = 10000
+	defaultHistory     = 10000
+)
+
+// Terminal control sequences.
+const (
+	clearLine = "\r\x1b[K" // Return to column 0 and clear the line
+)
+
+// Prompt kinds, which also label the prompt.
+const (
+	promptFilter = "filter"
+	promptSearch = "search"
+)
+
+// struct View is the terminal display. Entries and other lines are
+// printed above a status line, which shows the active filter and
+// search, or the prompt while one is being typed. Output can be
+// paused, in which case lines are held, up to PauseBuffer of them,
+// until it is resumed. The most recent lines are kept for browsing
+// in the pager. A View is safe for use by multiple goroutines.
+type View struct {
+	Highlight   string // color string for search matches
+	Status      color.Colorizer
+	PauseBuffer int
+	Size        func() (width, height int) // of the terminal
+
+	mu      sync.Mutex
+	out     io.Writer
+	filter  *regexp.Regexp
+	search  *regexp.Regexp
+	marker  formatter.Formatter // highlights search matches
+	prompt  string              // promptFilter or promptSearch while typing
+	input   []rune
+	message string // shown in the status line until the next key
+	muted   string // the muted sources, if any
+	sources string // status of the sources, if shown
+	drawn   bool   // whether the status line is on screen
+
+	paused    bool
+	held      []item // lines held while paused, oldest first
+	dropped   int    // lines dropped while paused, as held was full
+	dropWhile string // when they were dropped, for the message
+
+	history *history
+	pager   *pager // non-nil while the pager is open
+}
+
+// New returns a View that writes to out.
+func New(conf config.ViewConfig, out io.Writer) *View {
+	highlight := conf.Highlight
+	if highlight == "" {
+		highlight = defaultHighlight
+	}
+
+	status := conf.Status
+	if status == "" {
+		status = defaultStatus
+	}
+
+	pauseBuffer := conf.PauseBuffer
+	if pauseBuffer <= 0 {
+		pauseBuffer = defaultPauseBuffer
+	}
+
+	historySize := conf.History
+	if historySize <= 0 {
+		historySize = defaultHistory
+	}
+
+	return &View{
+		Highlight:   highlight,
+		Status:      color.GenerateColorizer(status),
+		PauseBuffer: pauseBuffer,
+		Size:        terminalSize(out),
+		out:         out,
+		history:     newHistory(historySize),
+	}
+}
+
+// terminalSize returns a function reporting the size of the
+// terminal out writes to, or 80x24 if it isn't one.
+func terminalSize(out io.Writer) func() (int, int) {
+	return func() (int, int) {
+		if f, ok := out.(*os.File); ok {
+			if w, h, err := term.GetSize(int(f.Fd())); err == nil {
+				return w, h
+			}
+		}
+
+		return 80, 24
+	}
+}
+
+// Close restores the terminal, leaving the pager if it is open.
+func (v *View) Close() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.pager != nil {
+		v.closePager()
+	}
+	v.clearStatus()
+}
+
+// Entry prints an entry, if it matches the live filter, with any
+// matches of the search highlighted. The filter and search see the
+// text as displayed, without colors. While paused or in the pager,
+// the entry is held instead.
+func (v *View) Entry(e *formatter.Entry) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.release(item{entry: e})
+}
+
+// Line prints a line that isn't an entry, such as a separator. It
+// isn't filtered. While paused or in the pager, the line is held
+// instead.
+func (v *View) Line(s string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.release(item{line: s})
+}
+
+// release adds an item to the history and prints it, or holds it
+// while paused or in the pager. It must be called with v.mu held.
+func (v *View) release(it item) {
+	if v.paused || v.pager != nil {
+		v.hold(it)
+		return
+	}
+
+	v.history.add(it)
+
+	if it.entry != nil && !v.matches(it.entry.Text) {
+		return
+	}
+
+	v.println(v.highlight(it.text()))
+}
+
+// matches reports whether s passes the live filter. It must be
+// called with v.mu held.
+func (v *View) matches(s string) bool {
+	return v.filter == nil || v.filter.MatchString(color.Strip(s))
+}
+
+// highlight highlights the matches of the search in s. It must be
+// called with v.mu held.
+func (v *View) highlight(s string) string {
+	if v.marker == nil {
+		return s
+	}
+
+	out, _ := v.marker.Format(s)
+	return out
+}
+
+// hold holds a line while paused or in the pager. If PauseBuffer
+// lines are already held, the oldest is dropped. It must be called
+// with v.mu held.
+func (v *View) hold(h item) {
+	if len(v.held) >= v.PauseBuffer {
+		v.held = v.held[1:]
+		v.dropped++
+		if v.paused {
+			v.dropWhile = "paused"
+		} else if v.dropWhile == "" {
+			v.dropWhile = "in the scrollback"
+		}
+	}
+	v.held = append(v.held, h)
+
+	if v.pager == nil {
+		v.redrawStatus()
+	}
+}
+
+// setPaused pauses or resumes output. It must be called with v.mu
+// held.
+func (v *View) setPaused(paused bool) {
+	v.paused = paused
+	if !paused && v.pager == nil {
+		v.flush()
+	}
+}
+
+// flush prints the held lines, followed by a count of any that were
+// dropped. It must be called with v.mu held.
+func (v *View) flush() {
+	held := v.held
+	v.held = nil
+
+	for _, it := range held {
+		v.release(it)
+	}
+
+	if v.dropped > 0 {
+		v.println(v.Status(fmt.Sprintf("%d lines dropped while %s", v.dropped, v.dropWhile)))
+	}
+
+	v.held = nil
+	v.dropped = 0
+	v.dropWhile = ""
+}
+
+// println prints s above the status line. It must be called with
+// v.mu held.
+func (v *View) println(s string) {
+	v.clearStatus()
+	fmt.Fprintln(v.out, s)
+	v.drawStatus()
+}
+
+// SetSources sets the status of the sources shown at the end of
+// the status line.
+func (v *View) SetSources(s string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.sources = s
+	if v.pager == nil {
+		v.redrawStatus()
+	}
+}
+
+// SetMuted sets the list of muted sources shown in the status line.
+func (v *View) SetMuted(s string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.muted = s
+	if v.pager == nil {
+		v.redrawStatus()
+	}
+}
+
+// Key handles the keys of the live filter and search, and reports
+// whether it used the key. While a prompt is open, it takes every
+// key. Otherwise:
+//
+//	/      prompts for a regexp to filter the live stream with
+//	?      prompts for a regexp to highlight; an empty one clears it
+//	Esc    clears the filter and the highlight
+//	Space  pauses or resumes output
+//	b      opens the pager (see pagerKey)
+func (v *View) Key(key keys.Key) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.message = ""
+
+	if v.pager != nil {
+		v.pagerKey(key)
+		return true
+	}
+
+	if v.prompt != "" {
+		v.promptKey(key)
+		v.redrawStatus()
+		return true
+	}
+
+	switch key.Code {
+	case keys.Escape:
+		v.setFilter(nil)
+		v.setSearch(nil)
+	case keys.Space:
+		v.setPaused(!v.paused)
+	case keys.RuneKey:
+		switch key.String() {
+		case "/":
+			v.prompt = promptFilter
+		case "?":
+			v.prompt = promptSearch
+		case "b":
+			v.openPager()
+			return true
+		default:
+			return false
+		}
+		v.input = nil
+	default:
+		return false
+	}
+
+	v.redrawStatus()
+
+	return true
+}
+
+// promptKey edits the prompt's input. Enter applies it, and Esc
+// abandons it. It must be called with v.mu held.
+func (v *View) promptKey(key keys.Key) {
+	switch key.Code {
+	case keys.Escape:
+		v.prompt = ""
+
+	case keys.Backspace, keys.CtrlH:
+		if len(v.input) > 0 {
+			v.input = v.input[:len(v.input)-1]
+		}
+
+	case keys.Space:
+		v.input = append(v.input, ' ')
+
+	case keys.RuneKey:
+		v.input = append(v.input, key.Runes...)
+
+	case keys.Enter:
+		var re *regexp.Regexp
+		if len(v.input) > 0 {
+			var err error
+			if re, err = regexp.Compile(string(v.input)); err != nil {
+				v.message = err.Error()
+				return // Leave the prompt open to fix it
+			}
+		}
+
+		if v.prompt == promptFilter {
+			v.setFilter(re)
+		} else {
+			v.setSearch(re)
+		}
+		v.prompt = ""
+	}
+}
+
+// setFilter sets the live filter; nil clears it. It must be called
+// with v.mu held.
+func (v *View) setFilter(re *regexp.Regexp) {
+	v.filter = re
+}
+
+// setSearch sets the highlighted search; nil clears it. It must be
+// called with v.mu held.
+func (v *View) setSearch(re *regexp.Regexp) {
+	v.search = re
+	v.marker = nil
+
+	if re != nil {
+		v.marker = regex.New(config.REConfig{v.Highlight: {re.String()}})
+	}
+}
+
+// status returns the text of the status line, or "" if there is
+// nothing to show. It must be called with v.mu held.
+func (v *View) status() string {
+	if v.prompt != "" {
+		s := v.prompt + ": " + string(v.input) + "_"
+		if v.message != "" {
+			s += "  (" + v.message + ")"
+		}
+		return s
+	}
+
+	var parts []string
+	if v.paused {
+		s := fmt.Sprintf("PAUSED (%d held", len(v.held))
+		if v.dropped > 0 {
+			s += fmt.Sprintf(", %d dropped", v.dropped)
+		}
+		parts = append(parts, s+")")
+	}
+	if v.filter != nil {
+		parts = append(parts, "filter: "+v.filter.String())
+	}
+	if v.search != nil {
+		parts = append(parts, "search: "+v.search.String())
+	}
+	if v.message != "" {
+		parts = append(parts, v.message)
+	}
+	if v.muted != "" {
+		parts = append(parts, v.muted)
+	}
+	if v.sources != "" {
+		parts = append(parts, v.sources)
+	}
+
+	return strings.Join(parts, "  ")
+}
+
+// drawStatus writes the status line, without a newline, so that it
+// stays at the bottom. It is cut to the width of the terminal, as a
+// wrapped line couldn't be cleared. It must be called with v.mu
+// held.
+func (v *View) drawStatus() {
+	s := v.status()
+	if s == "" {
+		return
+	}
+
+	w, _ := v.Size()
+	fmt.Fprint(v.out, v.Status(truncate(s, max(w-1, 1))))
+	v.drawn = true
+}
+
+// clearStatus erases the status line, if it is drawn. It must be
+// called with v.mu held.
+func (v *View) clearStatus() {
+	if v.drawn {
+		fmt.Fprint(v.out, clearLine)
+		v.drawn = false
+	}
+}
+
+// redrawStatus replaces the status line after a change. It must be
+// called with v.mu held.
+func (v *View) redrawStatus() {
+	v.clearStatus()
+	v.drawStatus()
+}
@@ -0,0 +1,187 @@
+package view
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"atomicgo.dev/keyboard/keys"
+	"github.com/assistcontrol/muxytail/config"
+	"github.com/assistcontrol/muxytail/formatter"
+	termcolor "github.com/gookit/color"
+)
+
+// newTestView returns a View with an uncolored status line, writing
+// to the returned buffer.
+func newTestView() (*View, *bytes.Buffer) {
+	var b bytes.Buffer
+	v := New(config.ViewConfig{}, &b)
+	v.Status = func(a ...any) string { return "[" + a[0].(string) + "]" }
+
+	return v, &b
+}
+
+// typeKeys sends s to v as keypresses, followed by Enter.
+func typeKeys(v *View, s string) {
+	for _, r := range s {
+		if r == ' ' {
+			v.Key(keys.Key{Code: keys.Space, Runes: []rune{r}})
+		} else {
+			v.Key(keys.Key{Code: keys.RuneKey, Runes: []rune{r}})
+		}
+	}
+	v.Key(keys.Key{Code: keys.Enter})
+}
+
+func TestFilter(t *testing.T) {
+	v, b := newTestView()
+
+	typeKeys(v, "/GET|POST")
+	b.Reset()
+
+	red := termcolor.HEXStyle("#FF0000").Sprint
+	v.Entry(formatter.NewEntry("test", red("GET")+" /index.html"))
+	v.Entry(formatter.NewEntry("test", "DELETE /index.html"))
+
+	expected := clearLine + red("GET") + " /index.html\n[filter: GET|POST]"
+	if b.String() != expected {
+		t.Errorf("output = %q, expected %q", b.String(), expected)
+	}
+
+	// Esc clears the filter and the status line
+	v.Key(keys.Key{Code: keys.Escape})
+	b.Reset()
+	v.Entry(formatter.NewEntry("test", "DELETE /index.html"))
+	if b.String() != "DELETE /index.html\n" {
+		t.Errorf("output after Esc = %q", b.String())
+	}
+}
+
+func TestSearch(t *testing.T) {
+	v, b := newTestView()
+	mark := termcolor.HEXStyle("#000000", "#FFFF00").Sprint
+
+	typeKeys(v, "?err")
+	b.Reset()
+	v.Entry(formatter.NewEntry("test", "an err here"))
+
+	expected := clearLine + "an " + mark("err") + " here\n[search: err]"
+	if b.String() != expected {
+		t.Errorf("output = %q, expected %q", b.String(), expected)
+	}
+
+	// An empty search clears just the search
+	typeKeys(v, "/x")
+	typeKeys(v, "?")
+	if s := v.status(); s != "filter: x" {
+		t.Errorf("status after empty search = %q, expected %q", s, "filter: x")
+	}
+
+	// n and N only step through matches in the pager
+	for _, r := range "nN" {
+		if v.Key(keys.Key{Code: keys.RuneKey, Runes: []rune{r}}) {
+			t.Errorf("Key(%c) handled in the live view", r)
+		}
+	}
+}
+
+func TestPrompt(t *testing.T) {
+	v, _ := newTestView()
+
+	if !v.Key(keys.Key{Code: keys.RuneKey, Runes: []rune{'/'}}) {
+		t.Fatal("Key(/) not handled")
+	}
+
+	// Every key goes to the prompt, even those with other uses
+	for _, r := range "qj(" {
+		if !v.Key(keys.Key{Code: keys.RuneKey, Runes: []rune{r}}) {
+			t.Errorf("Key(%c) not handled in prompt", r)
+		}
+	}
+	v.Key(keys.Key{Code: keys.Space})
+	if s := v.status(); s != "filter: qj( _" {
+		t.Errorf("status = %q", s)
+	}
+
+	// A bad regexp keeps the prompt open
+	v.Key(keys.Key{Code: keys.Enter})
+	if s := v.status(); !strings.HasPrefix(s, "filter: qj( _  (error parsing regexp") {
+		t.Errorf("status after bad regexp = %q", s)
+	}
+
+	v.Key(keys.Key{Code: keys.Backspace})
+	v.Key(keys.Key{Code: keys.Backspace})
+	v.Key(keys.Key{Code: keys.Enter})
+	if s := v.status(); s != "filter: qj" {
+		t.Errorf("status = %q, expected %q", s, "filter: qj")
+	}
+
+	// Esc abandons a prompt, leaving the filter alone
+	v.Key(keys.Key{Code: keys.RuneKey, Runes: []rune{'/'}})
+	v.Key(keys.Key{Code: keys.RuneKey, Runes: []rune{'z'}})
+	v.Key(keys.Key{Code: keys.Escape})
+	if s := v.status(); s != "filter: qj" {
+		t.Errorf("status after Esc in prompt = %q", s)
+	}
+
+	// Other keys are left to the caller
+	if v.Key(keys.Key{Code: keys.RuneKey, Runes: []rune{'q'}}) {
+		t.Error("Key(q) handled outside a prompt")
+	}
+}
+
+func TestPause(t *testing.T) {
+	v, b := newTestView()
+	v.PauseBuffer = 3
+	space := keys.Key{Code: keys.Space, Runes: []rune{' '}}
+
+	v.Key(space)
+	for _, s := range []string{"one", "two", "three", "four"} {
+		v.Entry(formatter.NewEntry("test", s))
+	}
+	v.Line("---")
+
+	if s := v.status(); s != "PAUSED (3 held, 2 dropped)" {
+		t.Errorf("status = %q", s)
+	}
+
+	b.Reset()
+	v.Key(space)
+
+	// The oldest lines were dropped
+	expected := clearLine + "three\nfour\n---\n[2 lines dropped while paused]\n"
+	if b.String() != expected {
+		t.Errorf("output on resume = %q, expected %q", b.String(), expected)
+	}
+
+	b.Reset()
+	v.Entry(formatter.NewEntry("test", "five"))
+	if b.String() != "five\n" {
+		t.Errorf("output after resume = %q", b.String())
+	}
+}
+
+func TestSetSources(t *testing.T) {
+	v, b := newTestView()
+	v.Size = func() (int, int) { return 20, 24 }
+
+	v.SetSources("a.log 1.0/s 2s ago | b.log 0.0/s idle")
+	if got, expected := b.String(), "[a.log 1.0/s 2s ago \x1b[0m]"; got != expected {
+		t.Errorf("status line = %q, expected %q", got, expected)
+	}
+
+	// The status is kept under printed lines
+	b.Reset()
+	v.Line("----")
+	if got, expected := b.String(), clearLine+"----\n[a.log 1.0/s 2s ago \x1b[0m]"; got != expected {
+		t.Errorf("output = %q, expected %q", got, expected)
+	}
+
+	// Muted sources come before their status
+	b.Reset()
+	v.Size = func() (int, int) { return 80, 24 }
+	v.SetMuted("muted: 2 b.log")
+	if got, expected := b.String(), clearLine+"[muted: 2 b.log  a.log 1.0/s 2s ago | b.log 0.0/s idle]"; got != expected {
+		t.Errorf("status line = %q, expected %q", got, expected)
+	}
+}